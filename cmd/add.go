@@ -0,0 +1,173 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
+	git "github.com/go-git/go-git/v5"
+	gitconfig "github.com/go-git/go-git/v5/config"
+	memory "github.com/go-git/go-git/v5/storage/memory"
+	cobra "github.com/spf13/cobra"
+)
+
+var (
+	errRepoExists = errors.New("repository is already configured")
+	errDirInUse   = errors.New("directory is used by another repository")
+
+	fullNameRegexp = regexp.MustCompile(`^[\w.-]+/[\w.-]+$`)
+)
+
+func init() {
+	var dir, branch string
+
+	addCmd := &cobra.Command{
+		Use:         "add URL|OWNER/NAME",
+		Short:       "Add a repository which isn't discovered, e.g. a repository of another user",
+		Annotations: mutating,
+		Args:        cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			runAdd(loadConfig(), args[0], dir, branch)
+		},
+	}
+
+	addCmd.Flags().StringVarP(&dir, "dir", "d", "", "Directory of the repository (default: derived from the owner and name)")
+	addCmd.Flags().StringVarP(&branch, "branch", "B", "", "Default branch of the repository (default: the HEAD of the remote)")
+
+	rootCmd.AddCommand(addCmd)
+}
+
+// isGithubURL reports whether a repository URL is on the configured GitHub instance.
+func isGithubURL(conf *Configuration, repoURL string) bool {
+	host := "github.com"
+	if conf.BaseURL != "" {
+		host = urlHost(conf.BaseURL)
+	}
+
+	return urlHost(repoURL) == host
+}
+
+// hostAuthURL adds the credentials of the configured repositories on the same host
+// to an HTTP(S) URL without credentials.
+func hostAuthURL(conf *Configuration, repoURL string) string {
+	u, err := url.Parse(repoURL)
+	if err != nil || u.User != nil || (u.Scheme != "https" && u.Scheme != "http") {
+		return repoURL
+	}
+
+	for _, r := range conf.Repos {
+		ru, err := url.Parse(r.URL)
+		if err == nil && ru.User != nil && strings.EqualFold(ru.Host, u.Host) {
+			u.User = ru.User
+
+			return u.String()
+		}
+	}
+
+	return repoURL
+}
+
+// lookupRepo returns the configuration of a repository given by its URL or its full name.
+// Repositories on GitHub are looked up using the API, the default branch of
+// other repositories is the given branch or the branch the HEAD of the remote points to.
+func lookupRepo(ctx context.Context, conf *Configuration, arg, branch string) (Repo, error) {
+	if fullNameRegexp.MatchString(arg) || isGithubURL(conf, arg) {
+		fullName := arg
+
+		if !fullNameRegexp.MatchString(arg) {
+			owner, name, err := repoFullName(normalizeURL(arg))
+			if err != nil {
+				return Repo{}, err
+			}
+
+			fullName = owner + "/" + name
+		}
+
+		client := newGithubClient(conf)
+		parts := strings.SplitN(fullName, "/", 2)
+
+		ghRepo, _, err := client.Repositories.Get(ctx, parts[0], parts[1])
+		if err != nil {
+			return Repo{}, err
+		}
+
+		repo, err := newRepo(ctx, conf, client, ghRepo)
+		if err != nil || fullNameRegexp.MatchString(arg) {
+			return repo, err
+		}
+
+		// Keep the given URL, which may use another protocol
+		repo.URL = arg
+		if u, err := url.Parse(arg); err == nil && u.User == nil {
+			repo.URL = authURL(conf, arg)
+		}
+
+		return repo, nil
+	}
+
+	owner, name, err := repoFullName(normalizeURL(arg))
+	if err != nil {
+		return Repo{}, err
+	}
+
+	repo := Repo{URL: hostAuthURL(conf, arg), Dir: repoDir(conf, owner+"/"+name), Branch: branch}
+
+	if branch == "" {
+		remote := git.NewRemote(memory.NewStorage(), &gitconfig.RemoteConfig{
+			Name: git.DefaultRemoteName,
+			URLs: []string{repo.URL},
+		})
+
+		head, err := remoteHead(ctx, remote)
+		if err != nil {
+			return Repo{}, err
+		}
+
+		repo.Branch = head.Short()
+	}
+
+	return repo, nil
+}
+
+func runAdd(conf *Configuration, arg, dir, branch string) {
+	repo, err := lookupRepo(context.Background(), conf, arg, branch)
+	fatalIfError(err)
+
+	if dir != "" {
+		repo.Dir, err = workspaceDir(conf, dir)
+		fatalIfError(err)
+	}
+
+	if branch != "" {
+		repo.Branch = branch
+	}
+
+	repo.Pinned = true
+
+	for i, r := range conf.Repos {
+		switch {
+		case sameRepoURL(r.URL, repo.URL) && (r.Pinned || dir != "" && !samePath(r.Dir, repo.Dir) ||
+			branch != "" && r.Branch != branch):
+			// The directory and branch of discovered repositories are kept, since update manages them
+			fatalError(fmt.Errorf("%s: %w", r.Dir, errRepoExists))
+
+			return
+		case sameRepoURL(r.URL, repo.URL):
+			// Discovered repositories are pinned, so that they are kept if they are no longer discovered
+			conf.Repos[i].Pinned = true
+			conf.save()
+
+			return
+		case samePath(r.Dir, repo.Dir):
+			fatalError(fmt.Errorf("%s: %w", r.Dir, errDirInUse))
+
+			return
+		}
+	}
+
+	conf.Repos = append(conf.Repos, repo)
+	conf.save()
+}
@@ -0,0 +1,226 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	color "github.com/fatih/color"
+	git "github.com/go-git/go-git/v5"
+	cobra "github.com/spf13/cobra"
+)
+
+var errNoOrigin = errors.New("no origin")
+
+type adoptOptions struct {
+	keep   bool
+	dryRun bool
+}
+
+func init() {
+	var opts adoptOptions
+
+	adoptCmd := &cobra.Command{
+		Use:   "adopt [DIR]...",
+		Short: "Adopt existing clones of the configured repositories instead of cloning them again",
+		Long: "Scan the given directories (default: the base directory) for git repositories whose origin is one " +
+			"of the configured repositories, and move them to the directory of the repository, or record their " +
+			"directory in the configuration if --keep is given.",
+		Annotations: mutating,
+		Run: func(cmd *cobra.Command, args []string) {
+			conf := loadConfig()
+
+			if len(args) == 0 {
+				args = []string{conf.BaseDir}
+			}
+
+			runAdopt(conf, args, opts)
+		},
+	}
+
+	adoptCmd.Flags().BoolVarP(&opts.keep, "keep", "k", false, "Keep the clones in place and record their directory in the configuration")
+	adoptCmd.Flags().BoolVarP(&opts.dryRun, "dry-run", "n", false, "Only show what would be done")
+
+	rootCmd.AddCommand(adoptCmd)
+}
+
+// originURL returns the URL of the origin remote of a repository.
+func originURL(repository *git.Repository) (string, error) {
+	origin, err := repository.Remote(git.DefaultRemoteName)
+	if errors.Is(err, git.ErrRemoteNotFound) {
+		return "", errNoOrigin
+	}
+
+	if err != nil {
+		return "", err
+	}
+
+	urls := origin.Config().URLs
+	if len(urls) == 0 {
+		return "", errNoOrigin
+	}
+
+	return urls[0], nil
+}
+
+// findClones returns the git repositories with a working tree below root.
+func findClones(root string) ([]string, error) {
+	var clones []string
+
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if !info.IsDir() || path != root && (info.Name() == git.GitDirName || isWorkspaceFile(path)) {
+			return nil
+		}
+
+		if pathExists(filepath.Join(path, git.GitDirName)) {
+			clones = append(clones, path)
+
+			return filepath.SkipDir
+		}
+
+		return nil
+	})
+
+	return clones, err
+}
+
+func samePath(a, b string) bool {
+	absA, errA := filepath.Abs(a)
+	absB, errB := filepath.Abs(b)
+
+	return errA == nil && errB == nil && absA == absB
+}
+
+// workspaceDir returns the directory of a repository as stored in the configuration,
+// which is relative to the base directory if the path is inside it.
+func workspaceDir(conf *Configuration, path string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+
+	base, err := filepath.Abs(conf.BaseDir)
+	if err != nil {
+		return "", err
+	}
+
+	rel, err := filepath.Rel(base, abs)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, "../") {
+		return abs, err
+	}
+
+	return conf.BaseDir + "/" + rel, nil
+}
+
+// adoptClone moves a clone to the directory of the matching repository, or records its directory.
+func adoptClone(conf *Configuration, repo *Repo, path string, opts adoptOptions) (string, error) {
+	if opts.keep {
+		dir, err := workspaceDir(conf, path)
+		if err != nil {
+			return "", err
+		}
+
+		repo.Dir = dir
+
+		return dryRunState(opts.dryRun, "recorded", "would record"), nil
+	}
+
+	if pathExists(repo.Dir) {
+		return color.RedString("not moved: " + repo.Dir + " exists"), nil
+	}
+
+	if !opts.dryRun {
+		err := os.MkdirAll(filepath.Dir(repo.Dir), 0o755)
+		if err != nil {
+			return "", err
+		}
+
+		err = os.Rename(path, repo.Dir)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return dryRunState(opts.dryRun, "moved to", "would move to") + "\t" + repo.Dir, nil
+}
+
+func runAdopt(conf *Configuration, roots []string, opts adoptOptions) {
+	var status StatusList
+	var recorded bool
+
+	adopted := make(map[int]string)
+
+	for _, root := range roots {
+		clones, err := findClones(root)
+		fatalIfError(err)
+
+		for _, path := range clones {
+			repository, err := git.PlainOpen(path)
+			if err != nil {
+				status.appendError(path, err)
+
+				continue
+			}
+
+			url, err := originURL(repository)
+			if err != nil {
+				status.appendError(path, err)
+
+				continue
+			}
+
+			i := -1
+
+			for j, r := range conf.Repos {
+				if sameRepoURL(r.URL, url) {
+					i = j
+
+					break
+				}
+			}
+
+			switch {
+			case i < 0:
+				status.append(path, color.YellowString("no matching repository")+"\t"+redactURL(url))
+
+				continue
+			case samePath(path, conf.Repos[i].Dir):
+				continue
+			case adopted[i] != "":
+				status.append(path, color.YellowString("duplicate of "+adopted[i]))
+
+				continue
+			}
+
+			state, err := adoptClone(conf, &conf.Repos[i], path, opts)
+			if err != nil {
+				status.appendError(path, err)
+
+				continue
+			}
+
+			adopted[i] = path
+			recorded = recorded || opts.keep && !opts.dryRun
+
+			status.append(path, state)
+		}
+	}
+
+	if len(status) == 0 {
+		fmt.Println("There are no clones to adopt.")
+
+		return
+	}
+
+	status.print()
+
+	if recorded {
+		conf.save()
+	}
+}
@@ -0,0 +1,250 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	formatconfig "github.com/go-git/go-git/v5/plumbing/format/config"
+	cobra "github.com/spf13/cobra"
+)
+
+const (
+	gitAliasesRepo      = "gr-git-aliases"
+	gitAliasesFile      = "aliases.json"
+	aliasSection        = "alias"
+	managedAliasOption  = "managedAlias"
+	gitConfigBackupExt  = ".gr.bak"
+	xdgConfigHomeEnvVar = "XDG_CONFIG_HOME"
+)
+
+type gitAlias struct {
+	Alias   string `json:"alias"`
+	Command string `json:"command"`
+}
+
+func init() {
+	var source ConfigSource
+	var dryRun bool
+
+	aliasesCmd := &cobra.Command{
+		Use:   "aliases",
+		Short: "Manage git aliases",
+		Run: func(cmd *cobra.Command, args []string) {
+			err := cmd.Help()
+			fatalIfError(err)
+		},
+	}
+
+	aliasesSyncCmd := &cobra.Command{
+		Use:   "sync",
+		Short: "Sync the global git aliases with the aliases stored on GitHub",
+		Run: func(cmd *cobra.Command, args []string) {
+			conf := loadConfig()
+
+			if source.Repo != "" {
+				conf.Aliases.Repo = source.Repo
+			}
+
+			if source.File != "" {
+				conf.Aliases.File = source.File
+			}
+
+			runAliasesSync(conf, dryRun)
+		},
+	}
+
+	aliasesSyncCmd.Flags().StringVar(&source.Repo, "repo", "",
+		"Repository containing the aliases, as OWNER/NAME or NAME (default: "+gitAliasesRepo+")")
+	aliasesSyncCmd.Flags().StringVar(&source.File, "file", "", "Path of the aliases file in the repository (default: "+gitAliasesFile+")")
+	aliasesSyncCmd.Flags().BoolVarP(&dryRun, "dry-run", "n", false, "Only show the changes")
+
+	aliasesCmd.AddCommand(aliasesSyncCmd)
+	rootCmd.AddCommand(aliasesCmd)
+}
+
+// globalGitConfigPath returns the path of the global git configuration. Like git,
+// ~/.gitconfig is used unless only the XDG configuration file exists.
+func globalGitConfigPath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+
+	path := filepath.Join(home, ".gitconfig")
+	if pathExists(path) {
+		return path, nil
+	}
+
+	xdgHome := os.Getenv(xdgConfigHomeEnvVar)
+	if xdgHome == "" {
+		xdgHome = filepath.Join(home, ".config")
+	}
+
+	xdgPath := filepath.Join(xdgHome, "git", "config")
+	if pathExists(xdgPath) {
+		return xdgPath, nil
+	}
+
+	return path, nil
+}
+
+// readGitConfig reads a git configuration file, which is empty if the file doesn't exist.
+func readGitConfig(path string) (*formatconfig.Config, error) {
+	cfg := formatconfig.New()
+
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return cfg, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return cfg, formatconfig.NewDecoder(bytes.NewReader(b)).Decode(cfg)
+}
+
+// writeGitConfig writes a git configuration file, keeping a backup of the previous file.
+func writeGitConfig(path string, cfg *formatconfig.Config) error {
+	var buf bytes.Buffer
+
+	err := formatconfig.NewEncoder(&buf).Encode(cfg)
+	if err != nil {
+		return err
+	}
+
+	old, err := ioutil.ReadFile(path)
+	if err == nil {
+		err = ioutil.WriteFile(path+gitConfigBackupExt, old, 0o600)
+	}
+
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Dir(path), 0o700)
+	if err != nil {
+		return err
+	}
+
+	return writeFileAtomic(path, buf.Bytes(), 0o600)
+}
+
+// updateGitConfig applies the changes done by fn to a git configuration file and returns
+// their description. The file is only written if it changed and dryRun isn't set.
+func updateGitConfig(path string, dryRun bool, fn func(*formatconfig.Config) ([]string, error)) ([]string, error) {
+	var before, after bytes.Buffer
+
+	cfg, err := readGitConfig(path)
+	if err != nil {
+		return nil, err
+	}
+
+	err = formatconfig.NewEncoder(&before).Encode(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	changes, err := fn(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	err = formatconfig.NewEncoder(&after).Encode(cfg)
+	if err != nil || dryRun || bytes.Equal(before.Bytes(), after.Bytes()) {
+		return changes, err
+	}
+
+	return changes, writeGitConfig(path, cfg)
+}
+
+// sourceRepo returns the owner and name of the repository of a source, which
+// defaults to the aliases repository of the user.
+func sourceRepo(conf *Configuration, source ConfigSource) (owner, name string) {
+	owner, name = conf.Username, gitAliasesRepo
+	if source.Repo != "" {
+		name = source.Repo
+		if i := strings.Index(name, "/"); i >= 0 {
+			owner, name = name[:i], name[i+1:]
+		}
+	}
+
+	return owner, name
+}
+
+// fetchSourceFile returns the content of a file stored in a repository on GitHub.
+func fetchSourceFile(conf *Configuration, source ConfigSource, defaultFile string) ([]byte, error) {
+	owner, name := sourceRepo(conf, source)
+
+	file := defaultFile
+	if source.File != "" {
+		file = source.File
+	}
+
+	client := newGithubClient(conf)
+
+	content, _, _, err := client.Repositories.GetContents(context.Background(), owner, name, file, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	decoded, err := content.GetContent()
+
+	return []byte(decoded), err
+}
+
+// fetchAliases returns the aliases stored in the configured repository.
+func fetchAliases(conf *Configuration) ([]gitAlias, error) {
+	var ga []gitAlias
+
+	content, err := fetchSourceFile(conf, conf.Aliases, gitAliasesFile)
+	if err != nil {
+		return nil, err
+	}
+
+	return ga, json.Unmarshal(content, &ga)
+}
+
+// syncAliases updates the alias section of cfg. The aliases previously managed by gr
+// which no longer exist are removed. It returns the description of the changes.
+func syncAliases(cfg *formatconfig.Config, aliases []gitAlias) ([]string, error) {
+	options := make([]configOption, 0, len(aliases))
+	for _, a := range aliases {
+		options = append(options, configOption{Key: aliasSection + "." + a.Alias, Value: a.Command})
+	}
+
+	return applyConfigOptions(cfg, options, managedAliasOption)
+}
+
+func runAliasesSync(conf *Configuration, dryRun bool) {
+	aliases, err := fetchAliases(conf)
+	fatalIfError(err)
+
+	path, err := globalGitConfigPath()
+	fatalIfError(err)
+
+	changes, err := updateGitConfig(path, dryRun, func(cfg *formatconfig.Config) ([]string, error) {
+		return syncAliases(cfg, aliases)
+	})
+	fatalIfError(err)
+
+	if len(changes) == 0 {
+		fmt.Println("Aliases in " + path + " are up to date.")
+
+		return
+	}
+
+	for _, c := range changes {
+		fmt.Println(c)
+	}
+
+	if !dryRun {
+		fmt.Println("Aliases saved to " + path + ", the previous file was saved to " + path + gitConfigBackupExt + ".")
+	}
+}
@@ -0,0 +1,101 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	plumbing "github.com/go-git/go-git/v5/plumbing"
+)
+
+const (
+	backendGoGit = "go-git"
+	backendGit   = "git"
+)
+
+var errUnknownBackend = errors.New("unknown git backend")
+
+// RepoStatus holds the state of a repository's worktree.
+type RepoStatus struct {
+	Branch string
+	Head   plumbing.Hash
+	Clean  bool
+}
+
+// PullResult holds the local branches which weren't fast-forwarded by a pull.
+type PullResult struct {
+	// Diverged holds the branches which diverged from their upstream.
+	Diverged []string
+	// Shallow holds the branches which couldn't be compared with their upstream
+	// because the history is shallow or partial.
+	Shallow []string
+}
+
+// GitBackend performs the git operations on the repositories.
+type GitBackend interface {
+	// Clone clones a repository into its directory.
+	Clone(ctx context.Context, repo Repo, opts CloneOptions) error
+	// UpdateClone downloads the LFS objects and, if needed, the submodules and the
+	// other references of a cloned repository. Its failures leave the clone usable.
+	UpdateClone(ctx context.Context, repo Repo, opts CloneOptions) error
+	// Pull updates the checked out branch of a repository and fast-forwards the other
+	// local branches. It returns the branches which weren't fast-forwarded.
+	Pull(ctx context.Context, repo Repo, opts CloneOptions) (PullResult, error)
+	// Push pushes the local branches of a repository which exist on the remote, along
+	// with the references selected by opts. It returns the updated remote references,
+	// or the ones which would be updated when doing a dry run.
+	Push(ctx context.Context, repo Repo, opts PushOptions) ([]RefUpdate, error)
+	// Status returns the state of the worktree of a repository.
+	Status(ctx context.Context, repo Repo) (RepoStatus, error)
+	// RemoteBranch returns the commit a branch points to on the remote,
+	// or plumbing.ZeroHash if the branch doesn't exist.
+	RemoteBranch(ctx context.Context, repo Repo, branch string) (plumbing.Hash, error)
+	// Checkout switches the worktree of a repository to a branch. If create is set, the
+	// branch is created at the checked out commit, keeping the changes of the worktree.
+	Checkout(ctx context.Context, repo Repo, branch string, create bool) error
+	// Commit commits the staged changes of a repository, or all changes of the tracked
+	// files if all is set. It returns the created commit.
+	Commit(ctx context.Context, repo Repo, message string, all bool) (plumbing.Hash, error)
+}
+
+// backend returns the git backend of a repository,
+// falling back to the backend of the workspace.
+func (conf *Configuration) backend(repo Repo) (GitBackend, error) {
+	name := conf.Backend
+	if repo.Backend != "" {
+		name = repo.Backend
+	}
+
+	switch name {
+	case "", backendGoGit:
+		return goGitBackend{}, nil
+	case backendGit:
+		return execBackend{}, nil
+	}
+
+	return nil, fmt.Errorf("%s: %w", name, errUnknownBackend)
+}
+
+// cloneArgs returns the arguments used for cloning a repository with the git executable.
+func cloneArgs(repo Repo, opts CloneOptions) []string {
+	args := []string{"clone", "--recurse-submodules"}
+
+	if opts.Depth > 0 {
+		args = append(args, "--depth="+strconv.Itoa(opts.Depth))
+	}
+
+	if opts.SingleBranch {
+		args = append(args, "--single-branch")
+	}
+
+	if opts.Filter != "" {
+		args = append(args, "--filter="+opts.Filter)
+	}
+
+	if repo.Branch != "" {
+		args = append(args, "--branch="+repo.Branch)
+	}
+
+	return append(args, "--", repo.URL, repo.Dir)
+}
@@ -0,0 +1,257 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	osfs "github.com/go-git/go-billy/v5/osfs"
+	git "github.com/go-git/go-git/v5"
+	plumbing "github.com/go-git/go-git/v5/plumbing"
+	transport "github.com/go-git/go-git/v5/plumbing/transport"
+)
+
+// execBackend performs the git operations using the git executable.
+// Its errors are translated to the go-git errors, so that they are reported the same way.
+type execBackend struct{}
+
+// translateGitError translates the output of failed git commands to the equivalent go-git errors.
+func translateGitError(err error) error {
+	var gerr *gitError
+	if !errors.As(err, &gerr) {
+		return err
+	}
+
+	switch {
+	case strings.Contains(gerr.output, "non-fast-forward"),
+		strings.Contains(gerr.output, "Not possible to fast-forward"):
+		return git.ErrNonFastForwardUpdate
+	case strings.Contains(gerr.output, "Authentication failed"),
+		strings.Contains(gerr.output, "terminal prompts disabled"):
+		return transport.ErrAuthenticationRequired
+	case strings.Contains(gerr.output, "Permission to"),
+		strings.Contains(gerr.output, "The requested URL returned error: 403"):
+		return transport.ErrAuthorizationFailed
+	case strings.Contains(gerr.output, "stale info"):
+		return errStaleLease
+	case strings.Contains(gerr.output, "nothing to commit"),
+		strings.Contains(gerr.output, "no changes added to commit"):
+		return errNothingToCommit
+	}
+
+	return err
+}
+
+// fastForwardTrackingBranches fast-forwards all local branches, except the checked out one,
+// whose upstream branch moved ahead. It returns the branches which diverged from their upstream.
+func fastForwardTrackingBranches(ctx context.Context, repo Repo) ([]string, error) {
+	var diverged []string
+
+	// Fails for a detached HEAD, in which case no branch is checked out
+	head, _ := outputGit(ctx, repo.Dir, "symbolic-ref", "-q", "HEAD")
+	head = strings.TrimSpace(head)
+
+	out, err := outputGit(ctx, repo.Dir, "for-each-ref",
+		"--format=%(refname) %(upstream) %(upstream:track,nobracket)", "refs/heads")
+	if err != nil {
+		return nil, err
+	}
+
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		fields := strings.SplitN(line, " ", 3)
+		if len(fields) < 3 || fields[1] == "" || fields[0] == head {
+			continue
+		}
+
+		track := fields[2]
+
+		switch {
+		case strings.Contains(track, "ahead") && strings.Contains(track, "behind"):
+			diverged = append(diverged, plumbing.ReferenceName(fields[0]).Short())
+		case strings.Contains(track, "behind"):
+			err = execGit(ctx, repo.Dir, "update-ref", fields[0], fields[1])
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return diverged, nil
+}
+
+func (execBackend) Clone(ctx context.Context, repo Repo, opts CloneOptions) error {
+	cmd := gitCommand(ctx, "", cloneArgs(repo, opts)...)
+	if repo.SkipLFS {
+		cmd.Env = append(cmd.Env, lfsSkipEnv)
+	}
+
+	return translateGitError(runGitCommand(ctx, cmd))
+}
+
+func (execBackend) UpdateClone(ctx context.Context, repo Repo, opts CloneOptions) error {
+	return pullLFS(ctx, repo, osfs.New(repo.Dir))
+}
+
+func (execBackend) Pull(ctx context.Context, repo Repo, opts CloneOptions) (PullResult, error) {
+	out, err := outputGit(ctx, repo.Dir, "status", "--porcelain")
+	if err != nil {
+		return PullResult{}, err
+	}
+
+	if out != "" {
+		return PullResult{}, git.ErrWorktreeNotClean
+	}
+
+	lfs, err := needsLFS(repo, osfs.New(repo.Dir))
+	if err != nil {
+		return PullResult{}, err
+	}
+
+	cmd := gitCommand(ctx, repo.Dir, "pull", "--ff-only", "--recurse-submodules")
+
+	if lfs {
+		err = installLFS(ctx, repo)
+		if err != nil {
+			return PullResult{}, err
+		}
+	} else {
+		cmd.Env = append(cmd.Env, lfsSkipEnv)
+	}
+
+	err = runGitCommand(ctx, cmd)
+	if err != nil {
+		return PullResult{}, translateGitError(err)
+	}
+
+	diverged, err := fastForwardTrackingBranches(ctx, repo)
+	if err != nil {
+		return PullResult{}, err
+	}
+
+	return PullResult{Diverged: diverged}, pullLFS(ctx, repo, osfs.New(repo.Dir))
+}
+
+func (execBackend) Push(ctx context.Context, repo Repo, opts PushOptions) ([]RefUpdate, error) {
+	repository, err := git.PlainOpen(repo.Dir)
+	if err != nil {
+		return nil, err
+	}
+
+	out, err := outputGit(ctx, repo.Dir, "ls-remote", git.DefaultRemoteName)
+	if err != nil {
+		return nil, translateGitError(err)
+	}
+
+	remoteRefs := make(map[plumbing.ReferenceName]plumbing.Hash)
+
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 2 {
+			remoteRefs[plumbing.ReferenceName(fields[1])] = plumbing.NewHash(fields[0])
+		}
+	}
+
+	updates, err := planPush(repository, remoteRefs, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(updates) == 0 {
+		return nil, git.NoErrAlreadyUpToDate
+	}
+
+	if opts.DryRun {
+		return updates, nil
+	}
+
+	args := []string{"push", "--porcelain"}
+	specs := make([]string, 0, len(updates))
+
+	for _, u := range updates {
+		if u.Forced {
+			args = append(args, "--force-with-lease="+u.Name.String()+":"+u.Old.String())
+		}
+
+		specs = append(specs, u.Name.String()+":"+u.Name.String())
+	}
+
+	args = append(append(args, git.DefaultRemoteName), specs...)
+
+	err = execGit(ctx, repo.Dir, args...)
+	if err != nil {
+		return nil, translateGitError(err)
+	}
+
+	return updates, setUpstreams(repository, updates)
+}
+
+func (execBackend) Status(ctx context.Context, repo Repo) (RepoStatus, error) {
+	var repoStatus RepoStatus
+
+	out, err := outputGit(ctx, repo.Dir, "status", "--porcelain=v2", "--branch")
+	if err != nil {
+		return repoStatus, err
+	}
+
+	repoStatus.Clean = true
+
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		switch {
+		case strings.HasPrefix(line, "# branch.oid "):
+			repoStatus.Head = plumbing.NewHash(strings.TrimPrefix(line, "# branch.oid "))
+		case strings.HasPrefix(line, "# branch.head "):
+			repoStatus.Branch = strings.TrimPrefix(line, "# branch.head ")
+			if repoStatus.Branch == "(detached)" {
+				repoStatus.Branch = plumbing.HEAD.String()
+			}
+		case strings.HasPrefix(line, "#"), line == "":
+		default:
+			repoStatus.Clean = false
+		}
+	}
+
+	return repoStatus, nil
+}
+
+func (execBackend) RemoteBranch(ctx context.Context, repo Repo, branch string) (plumbing.Hash, error) {
+	out, err := outputGit(ctx, repo.Dir, "ls-remote", git.DefaultRemoteName,
+		plumbing.NewBranchReferenceName(branch).String())
+	if err != nil {
+		return plumbing.ZeroHash, translateGitError(err)
+	}
+
+	fields := strings.Fields(out)
+	if len(fields) == 0 {
+		return plumbing.ZeroHash, nil
+	}
+
+	return plumbing.NewHash(fields[0]), nil
+}
+
+func (execBackend) Checkout(ctx context.Context, repo Repo, branch string, create bool) error {
+	args := []string{"checkout"}
+	if create {
+		args = append(args, "-b")
+	}
+
+	return translateGitError(execGit(ctx, repo.Dir, append(args, branch, "--")...))
+}
+
+func (execBackend) Commit(ctx context.Context, repo Repo, message string, all bool) (plumbing.Hash, error) {
+	args := []string{"commit", "-m", message}
+	if all {
+		args = append(args, "-a")
+	}
+
+	err := execGit(ctx, repo.Dir, args...)
+	if err != nil {
+		return plumbing.ZeroHash, translateGitError(err)
+	}
+
+	out, err := outputGit(ctx, repo.Dir, "rev-parse", "HEAD")
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	return plumbing.NewHash(strings.TrimSpace(out)), nil
+}
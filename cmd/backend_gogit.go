@@ -0,0 +1,392 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	git "github.com/go-git/go-git/v5"
+	gitconfig "github.com/go-git/go-git/v5/config"
+	plumbing "github.com/go-git/go-git/v5/plumbing"
+	transport "github.com/go-git/go-git/v5/plumbing/transport"
+)
+
+// goGitBackend performs the git operations using go-git.
+// Partial clones, shallow clones and LFS are handled using the git executable.
+type goGitBackend struct{}
+
+func cloneRepo(ctx context.Context, repo Repo, opts CloneOptions) (*git.Repository, error) {
+	if opts.Filter != "" {
+		// go-git doesn't support partial clones
+		cmd := gitCommand(ctx, "", cloneArgs(repo, opts)...)
+		if repo.SkipLFS {
+			cmd.Env = append(cmd.Env, lfsSkipEnv)
+		}
+
+		err := runGitCommand(ctx, cmd)
+		if err != nil {
+			return nil, err
+		}
+
+		return git.PlainOpen(repo.Dir)
+	}
+
+	cloneOpts := &git.CloneOptions{
+		URL:               repo.URL,
+		RecurseSubmodules: git.DefaultSubmoduleRecursionDepth,
+		Depth:             opts.Depth,
+		SingleBranch:      opts.SingleBranch,
+	}
+
+	if opts.SingleBranch && repo.Branch != "" {
+		cloneOpts.ReferenceName = plumbing.NewBranchReferenceName(repo.Branch)
+	}
+
+	return git.PlainCloneContext(ctx, repo.Dir, false, cloneOpts)
+}
+
+// isPartialOrShallow reports whether a repository is a partial or shallow clone.
+// go-git can't fetch missing objects of partial clones and doesn't send the shallow
+// commits to the server unless the depth changes, so such clones can't be updated by go-git.
+func isPartialOrShallow(repository *git.Repository, opts CloneOptions) (bool, error) {
+	if opts.Filter != "" {
+		return true, nil
+	}
+
+	shallows, err := repository.Storer.Shallow()
+
+	return len(shallows) > 0, err
+}
+
+func pullWorktree(ctx context.Context, repo Repo, repository *git.Repository, workTree *git.Worktree, opts CloneOptions) error {
+	cli, err := isPartialOrShallow(repository, opts)
+	if err != nil {
+		return err
+	}
+
+	// go-git refuses to update worktrees in which LFS replaced the pointers
+	lfs, err := needsLFS(repo, workTree.Filesystem)
+	if err != nil {
+		return err
+	}
+
+	if !cli && !lfs {
+		return workTree.PullContext(ctx, &git.PullOptions{
+			RecurseSubmodules: git.DefaultSubmoduleRecursionDepth,
+		})
+	}
+
+	cmd := gitCommand(ctx, repo.Dir, "pull", "--ff-only", "--recurse-submodules")
+
+	if lfs {
+		err = installLFS(ctx, repo)
+		if err != nil {
+			return err
+		}
+	} else {
+		cmd.Env = append(cmd.Env, lfsSkipEnv)
+	}
+
+	return runGitCommand(ctx, cmd)
+}
+
+// fetchAllRefs fetches all references of the remote repository. Single-branch,
+// partial and shallow clones only fetch the branches they track, so that the
+// history of all other branches doesn't get downloaded.
+func fetchAllRefs(ctx context.Context, repository *git.Repository, opts CloneOptions) error {
+	if opts.SingleBranch {
+		return nil
+	}
+
+	partial, err := isPartialOrShallow(repository, opts)
+	if err != nil || partial {
+		return err
+	}
+
+	err = repository.FetchContext(ctx, &git.FetchOptions{
+		RefSpecs: []gitconfig.RefSpec{"refs/*:refs/*"},
+	})
+	// Ignore NoErrAlreadyUpToDate and ErrForceNeeded, diverged branches are reported by runPull
+	if errors.Is(err, git.NoErrAlreadyUpToDate) || errors.Is(err, git.ErrForceNeeded) {
+		return nil
+	}
+
+	return err
+}
+
+// updateClone downloads the LFS objects, updates the submodules
+// and fetches all references of a freshly cloned or pulled repository.
+func updateClone(ctx context.Context, repo Repo, repository *git.Repository, opts CloneOptions) error {
+	workTree, err := repository.Worktree()
+	if err != nil {
+		return err
+	}
+
+	err = pullLFS(ctx, repo, workTree.Filesystem)
+	if err != nil {
+		return err
+	}
+
+	submodules, err := workTree.Submodules()
+	if err != nil {
+		return err
+	}
+
+	for _, s := range submodules {
+		err := pullSubmodule(ctx, s)
+		if err != nil {
+			return err
+		}
+	}
+
+	return fetchAllRefs(ctx, repository, opts)
+}
+
+func (goGitBackend) Clone(ctx context.Context, repo Repo, opts CloneOptions) error {
+	_, err := cloneRepo(ctx, repo, opts)
+
+	return err
+}
+
+func (goGitBackend) UpdateClone(ctx context.Context, repo Repo, opts CloneOptions) error {
+	repository, err := git.PlainOpen(repo.Dir)
+	if err != nil {
+		return err
+	}
+
+	return updateClone(ctx, repo, repository, opts)
+}
+
+func (goGitBackend) Pull(ctx context.Context, repo Repo, opts CloneOptions) (PullResult, error) {
+	repository, err := git.PlainOpen(repo.Dir)
+	if err != nil {
+		return PullResult{}, err
+	}
+
+	workTree, err := repository.Worktree()
+	if err != nil {
+		return PullResult{}, err
+	}
+
+	repoStatus, err := worktreeStatus(repository, workTree)
+	if err != nil {
+		return PullResult{}, err
+	}
+
+	if !repoStatus.IsClean() {
+		return PullResult{}, git.ErrWorktreeNotClean
+	}
+
+	err = pullWorktree(ctx, repo, repository, workTree, opts)
+	// Ignore NoErrAlreadyUpToDate
+	if err != nil && !errors.Is(err, git.NoErrAlreadyUpToDate) {
+		return PullResult{}, err
+	}
+
+	result, err := fastForwardBranches(repository)
+	if err != nil {
+		return PullResult{}, err
+	}
+
+	return result, updateClone(ctx, repo, repository, opts)
+}
+
+func (goGitBackend) Push(ctx context.Context, repo Repo, opts PushOptions) ([]RefUpdate, error) {
+	repository, err := git.PlainOpen(repo.Dir)
+	if err != nil {
+		return nil, err
+	}
+
+	remote, err := repository.Remote(git.DefaultRemoteName)
+	if err != nil {
+		return nil, err
+	}
+
+	refs, err := remote.ListContext(ctx, &git.ListOptions{})
+	if err != nil && !errors.Is(err, transport.ErrEmptyRemoteRepository) {
+		return nil, err
+	}
+
+	remoteRefs := make(map[plumbing.ReferenceName]plumbing.Hash, len(refs))
+	for _, r := range refs {
+		remoteRefs[r.Name()] = r.Hash()
+	}
+
+	updates, err := planPush(repository, remoteRefs, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(updates) == 0 {
+		return nil, git.NoErrAlreadyUpToDate
+	}
+
+	if opts.DryRun {
+		return updates, nil
+	}
+
+	pushOpts := &git.PushOptions{}
+
+	for _, u := range updates {
+		pushOpts.RefSpecs = append(pushOpts.RefSpecs, u.refSpec())
+
+		if u.Forced {
+			pushOpts.RequireRemoteRefs = append(pushOpts.RequireRemoteRefs,
+				gitconfig.RefSpec(u.Old.String()+":"+u.Name.String()))
+		}
+	}
+
+	err = repository.PushContext(ctx, pushOpts)
+	if err != nil {
+		return nil, err
+	}
+
+	return updates, setUpstreams(repository, updates)
+}
+
+func (goGitBackend) Status(ctx context.Context, repo Repo) (RepoStatus, error) {
+	repository, err := git.PlainOpen(repo.Dir)
+	if err != nil {
+		return RepoStatus{}, err
+	}
+
+	head, err := repository.Head()
+	if err != nil {
+		return RepoStatus{}, err
+	}
+
+	workTree, err := repository.Worktree()
+	if err != nil {
+		return RepoStatus{}, err
+	}
+
+	repoStatus, err := worktreeStatus(repository, workTree)
+	if err != nil {
+		return RepoStatus{}, err
+	}
+
+	return RepoStatus{
+		Branch: head.Name().Short(),
+		Head:   head.Hash(),
+		Clean:  repoStatus.IsClean(),
+	}, nil
+}
+
+func (goGitBackend) RemoteBranch(ctx context.Context, repo Repo, branch string) (plumbing.Hash, error) {
+	repository, err := git.PlainOpen(repo.Dir)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	remote, err := repository.Remote(git.DefaultRemoteName)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	remoteRef, err := remote.ListContext(ctx, &git.ListOptions{})
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	for _, r := range remoteRef {
+		if r.Name() == plumbing.NewBranchReferenceName(branch) {
+			return r.Hash(), nil
+		}
+	}
+
+	return plumbing.ZeroHash, nil
+}
+
+func (goGitBackend) Checkout(ctx context.Context, repo Repo, branch string, create bool) error {
+	repository, err := git.PlainOpen(repo.Dir)
+	if err != nil {
+		return err
+	}
+
+	workTree, err := repository.Worktree()
+	if err != nil {
+		return err
+	}
+
+	// go-git considers the files replaced by LFS as modified
+	lfs, err := needsLFS(repo, workTree.Filesystem)
+	if err != nil {
+		return err
+	}
+
+	if lfs {
+		return execBackend{}.Checkout(ctx, repo, branch, create)
+	}
+
+	name := plumbing.NewBranchReferenceName(branch)
+	opts := &git.CheckoutOptions{Branch: name, Create: create, Keep: create}
+
+	_, err = repository.Reference(name, true)
+	if !create && errors.Is(err, plumbing.ErrReferenceNotFound) {
+		// Create the branch from the remote branch, like git does
+		remote, err := repository.Reference(plumbing.NewRemoteReferenceName(git.DefaultRemoteName, branch), true)
+		if err != nil {
+			return err
+		}
+
+		err = repository.CreateBranch(&gitconfig.Branch{
+			Name:   branch,
+			Remote: git.DefaultRemoteName,
+			Merge:  name,
+		})
+		if err != nil && !errors.Is(err, git.ErrBranchExists) {
+			return err
+		}
+
+		opts.Create = true
+		opts.Hash = remote.Hash()
+	}
+
+	return workTree.Checkout(opts)
+}
+
+func (goGitBackend) Commit(ctx context.Context, repo Repo, message string, all bool) (plumbing.Hash, error) {
+	repository, err := git.PlainOpen(repo.Dir)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	workTree, err := repository.Worktree()
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	// go-git would stage the files replaced by LFS along with the other modified files
+	lfs, err := needsLFS(repo, workTree.Filesystem)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	repoConf, err := repository.Config()
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	// go-git can't use the configured GPG or SSH signing keys and doesn't run hooks
+	sign := strings.EqualFold(repoConf.Raw.Section("commit").Option("gpgSign"), "true")
+
+	if lfs || sign || hasCommitHooks(repo, repoConf.Raw) {
+		return execBackend{}.Commit(ctx, repo, message, all)
+	}
+
+	repoStatus, err := worktreeStatus(repository, workTree)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	if !hasChanges(repoStatus, all) {
+		return plumbing.ZeroHash, errNothingToCommit
+	}
+
+	author, err := commitAuthor(repository)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	return workTree.Commit(message, &git.CommitOptions{All: all, Author: author})
+}
@@ -0,0 +1,217 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	git "github.com/go-git/go-git/v5"
+	plumbing "github.com/go-git/go-git/v5/plumbing"
+	transport "github.com/go-git/go-git/v5/plumbing/transport"
+)
+
+// setupGit isolates the git executable from the configuration of the user.
+func setupGit(t *testing.T) {
+	t.Helper()
+
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git executable not found")
+	}
+
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("GIT_CONFIG_NOSYSTEM", "1")
+	t.Setenv("GIT_AUTHOR_NAME", "Test")
+	t.Setenv("GIT_AUTHOR_EMAIL", "test@example.com")
+	t.Setenv("GIT_COMMITTER_NAME", "Test")
+	t.Setenv("GIT_COMMITTER_EMAIL", "test@example.com")
+}
+
+func runGit(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+
+	out, err := outputGit(context.Background(), dir, args...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return out
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+
+	err := ioutil.WriteFile(path, []byte(content), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// newBareRepo returns the URL of a bare repository holding a single commit on main.
+func newBareRepo(t *testing.T) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	bare := filepath.Join(dir, "origin.git")
+	seed := filepath.Join(dir, "seed")
+
+	runGit(t, dir, "init", "--bare", "-b", "main", bare)
+	runGit(t, dir, "init", "-b", "main", seed)
+	writeFile(t, filepath.Join(seed, "README"), "seed\n")
+	runGit(t, seed, "add", "README")
+	runGit(t, seed, "commit", "-m", "Initial commit")
+	runGit(t, seed, "push", bare, "main")
+
+	return "file://" + bare
+}
+
+func headHash(t *testing.T, dir string) plumbing.Hash {
+	t.Helper()
+
+	repository, err := git.PlainOpen(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	head, err := repository.Head()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return head.Hash()
+}
+
+var testBackends = map[string]GitBackend{
+	backendGoGit: goGitBackend{},
+	backendGit:   execBackend{},
+}
+
+func TestBackends(t *testing.T) {
+	for name, backend := range testBackends {
+		backend := backend
+
+		t.Run(name, func(t *testing.T) {
+			setupGit(t)
+
+			ctx := context.Background()
+			url := newBareRepo(t)
+			dir := t.TempDir()
+			repo := Repo{URL: url, Dir: filepath.Join(dir, "a"), Branch: "main"}
+			other := Repo{URL: url, Dir: filepath.Join(dir, "b"), Branch: "main"}
+
+			for _, r := range []Repo{repo, other} {
+				err := backend.Clone(ctx, r, CloneOptions{})
+				if err != nil {
+					t.Fatalf("Clone: %v", err)
+				}
+
+				err = backend.UpdateClone(ctx, r, CloneOptions{})
+				if err != nil {
+					t.Fatalf("UpdateClone: %v", err)
+				}
+			}
+
+			status, err := backend.Status(ctx, repo)
+			if err != nil {
+				t.Fatalf("Status: %v", err)
+			}
+
+			if status.Branch != "main" || !status.Clean || status.Head != headHash(t, repo.Dir) {
+				t.Errorf("Status of a fresh clone = %+v", status)
+			}
+
+			runGit(t, repo.Dir, "config", "user.name", "Test")
+			runGit(t, repo.Dir, "config", "user.email", "test@example.com")
+
+			_, err = backend.Commit(ctx, repo, "Nothing", true)
+			if !errors.Is(err, errNothingToCommit) {
+				t.Errorf("Commit without changes: got %v, want %v", err, errNothingToCommit)
+			}
+
+			writeFile(t, filepath.Join(repo.Dir, "README"), "changed\n")
+
+			status, err = backend.Status(ctx, repo)
+			if err != nil || status.Clean {
+				t.Errorf("Status of a changed worktree = %+v, %v", status, err)
+			}
+
+			hash, err := backend.Commit(ctx, repo, "Change", true)
+			if err != nil {
+				t.Fatalf("Commit: %v", err)
+			}
+
+			if hash != headHash(t, repo.Dir) {
+				t.Errorf("Commit returned %s, HEAD is %s", hash, headHash(t, repo.Dir))
+			}
+
+			updates, err := backend.Push(ctx, repo, PushOptions{})
+			if err != nil {
+				t.Fatalf("Push: %v", err)
+			}
+
+			if len(updates) != 1 || updates[0].New != hash || updates[0].Commits != 1 {
+				t.Errorf("Push updates = %+v", updates)
+			}
+
+			remote, err := backend.RemoteBranch(ctx, repo, "main")
+			if err != nil || remote != hash {
+				t.Errorf("RemoteBranch = %s, %v, want %s", remote, err, hash)
+			}
+
+			_, err = backend.Pull(ctx, other, CloneOptions{})
+			if err != nil {
+				t.Fatalf("Pull: %v", err)
+			}
+
+			if headHash(t, other.Dir) != hash {
+				t.Errorf("HEAD after pull = %s, want %s", headHash(t, other.Dir), hash)
+			}
+
+			// Diverge from the pushed commit
+			runGit(t, other.Dir, "reset", "-q", "--hard", "HEAD~1")
+			runGit(t, other.Dir, "commit", "-q", "--allow-empty", "-m", "Diverged")
+
+			_, err = backend.Push(ctx, other, PushOptions{})
+			if !errors.Is(err, git.ErrNonFastForwardUpdate) {
+				t.Errorf("Push of a diverged branch: got %v, want %v", err, git.ErrNonFastForwardUpdate)
+			}
+		})
+	}
+}
+
+func TestTranslateGitError(t *testing.T) {
+	other := errors.New("other")
+
+	tests := []struct {
+		output string
+		want   error
+	}{
+		{"! [rejected] main -> main (non-fast-forward)", git.ErrNonFastForwardUpdate},
+		{"fatal: Not possible to fast-forward, aborting.", git.ErrNonFastForwardUpdate},
+		{"fatal: Authentication failed for 'https://github.com/o/r.git/'", transport.ErrAuthenticationRequired},
+		{"fatal: could not read Username: terminal prompts disabled", transport.ErrAuthenticationRequired},
+		{"remote: Permission to o/r.git denied to u.", transport.ErrAuthorizationFailed},
+		{"fatal: The requested URL returned error: 403", transport.ErrAuthorizationFailed},
+		{"! [rejected] main -> main (stale info)", errStaleLease},
+		{"nothing to commit, working tree clean", errNothingToCommit},
+		{"no changes added to commit (use \"git add\" and/or \"git commit -a\")", errNothingToCommit},
+	}
+
+	for _, tt := range tests {
+		err := translateGitError(&gitError{command: "push", output: tt.output, err: other})
+		if !errors.Is(err, tt.want) {
+			t.Errorf("translateGitError(%q) = %v, want %v", tt.output, err, tt.want)
+		}
+	}
+
+	gerr := &gitError{command: "fetch", output: "fatal: unknown failure", err: other}
+	if err := translateGitError(gerr); err != gerr {
+		t.Errorf("translateGitError changed %v to %v", gerr, err)
+	}
+
+	if err := translateGitError(other); err != other {
+		t.Errorf("translateGitError changed %v to %v", other, err)
+	}
+}
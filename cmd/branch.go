@@ -0,0 +1,25 @@
+package cmd
+
+import (
+	"context"
+
+	cobra "github.com/spf13/cobra"
+)
+
+func init() {
+	branchCmd := &cobra.Command{
+		Use:         "branch <name>",
+		Short:       "Create and check out a branch in all repositories",
+		Annotations: mutating,
+		Args:        cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			repoLoop(func(ctx context.Context, conf *Configuration, repo Repo, status *StatusList) {
+				switchBranch(ctx, conf, repo, status, args[0], true)
+			}, "Branching")
+		},
+	}
+
+	addGroupFlag(branchCmd)
+
+	rootCmd.AddCommand(branchCmd)
+}
@@ -0,0 +1,115 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+
+	color "github.com/fatih/color"
+	git "github.com/go-git/go-git/v5"
+	plumbing "github.com/go-git/go-git/v5/plumbing"
+	cobra "github.com/spf13/cobra"
+)
+
+func init() {
+	checkoutCmd := &cobra.Command{
+		Use:         "checkout [branch]",
+		Short:       "Check out a branch in all repositories, by default the branch from the configuration",
+		Annotations: mutating,
+		Args:        cobra.MaximumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			var branch string
+			if len(args) > 0 {
+				branch = args[0]
+			}
+
+			repoLoop(func(ctx context.Context, conf *Configuration, repo Repo, status *StatusList) {
+				runCheckout(ctx, conf, repo, status, branch)
+			}, "Checking out")
+		},
+	}
+
+	addGroupFlag(checkoutCmd)
+
+	rootCmd.AddCommand(checkoutCmd)
+}
+
+// switchBranch checks out a branch, creating it if create is set and it doesn't exist.
+// Existing branches are only checked out in clean worktrees.
+func switchBranch(ctx context.Context, conf *Configuration, repo Repo, status *StatusList, branch string, create bool) {
+	if conf.Mirror {
+		status.appendError(repo.Dir, errMirrorWorkspace)
+
+		return
+	}
+
+	repository, err := git.PlainOpen(repo.Dir)
+	if errors.Is(err, git.ErrRepositoryNotExists) {
+		status.append(repo.Dir, color.RedString("absent"))
+
+		return
+	}
+
+	if err != nil {
+		status.appendError(repo.Dir, err)
+
+		return
+	}
+
+	backend, err := conf.backend(repo)
+	if err != nil {
+		status.appendError(repo.Dir, err)
+
+		return
+	}
+
+	repoStatus, err := backend.Status(ctx, repo)
+	if err != nil {
+		status.appendError(repo.Dir, err)
+
+		return
+	}
+
+	if repoStatus.Branch == branch {
+		status.append(repo.Dir, color.GreenString(branch))
+
+		return
+	}
+
+	_, err = repository.Reference(plumbing.NewBranchReferenceName(branch), true)
+	exists := err == nil
+
+	if err != nil && !errors.Is(err, plumbing.ErrReferenceNotFound) {
+		status.appendError(repo.Dir, err)
+
+		return
+	}
+
+	if (exists || !create) && !repoStatus.Clean {
+		status.append(repo.Dir, color.RedString(repoStatus.Branch)+"\t"+color.RedString("dirty"))
+
+		return
+	}
+
+	err = backend.Checkout(ctx, repo, branch, create && !exists)
+	if err != nil {
+		status.appendError(repo.Dir, err)
+
+		return
+	}
+
+	if create && !exists {
+		status.append(repo.Dir, color.GreenString(branch)+"\t"+color.YellowString("created"))
+
+		return
+	}
+
+	status.append(repo.Dir, color.GreenString(branch))
+}
+
+func runCheckout(ctx context.Context, conf *Configuration, repo Repo, status *StatusList, branch string) {
+	if branch == "" {
+		branch = repo.Branch
+	}
+
+	switchBranch(ctx, conf, repo, status, branch, false)
+}
@@ -0,0 +1,390 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	color "github.com/fatih/color"
+	git "github.com/go-git/go-git/v5"
+	plumbing "github.com/go-git/go-git/v5/plumbing"
+	cobra "github.com/spf13/cobra"
+)
+
+const defaultArchiveDir = ".gr.archive"
+
+var errCleanAction = errors.New("choose what to do with untracked entries using --adopt, --delete or --archive")
+
+type cleanOptions struct {
+	adopt      bool
+	delete     bool
+	archive    bool
+	archiveDir string
+	dryRun     bool
+}
+
+func init() {
+	var opts cleanOptions
+
+	cleanCmd := &cobra.Command{
+		Use:         "clean",
+		Short:       "Adopt, delete or archive the untracked files and directories in the base directory",
+		Annotations: mutating,
+		Run: func(cmd *cobra.Command, args []string) {
+			if opts.delete && opts.archive || !opts.adopt && !opts.delete && !opts.archive {
+				fatalError(errCleanAction)
+
+				return
+			}
+
+			runClean(loadConfig(), opts)
+		},
+	}
+
+	cleanCmd.Flags().BoolVar(&opts.adopt, "adopt", false,
+		"Add the untracked repositories whose origin is on a known host to the configuration")
+	cleanCmd.Flags().BoolVar(&opts.delete, "delete", false, "Delete the untracked entries, unless they contain unpushed work")
+	cleanCmd.Flags().BoolVar(&opts.archive, "archive", false, "Move the untracked entries into the archive directory")
+	cleanCmd.Flags().StringVar(&opts.archiveDir, "archive-dir", "",
+		"Archive directory (default: "+defaultArchiveDir+" in the base directory)")
+	cleanCmd.Flags().BoolVarP(&opts.dryRun, "dry-run", "n", false, "Only show what would be done")
+
+	rootCmd.AddCommand(cleanCmd)
+}
+
+// normalizeURL converts scp-like SSH addresses (e.g. git@github.com:OWNER/NAME.git) to URLs.
+func normalizeURL(repoURL string) string {
+	if strings.Contains(repoURL, "://") {
+		return repoURL
+	}
+
+	if i := strings.Index(repoURL, ":"); i > 0 && !strings.Contains(repoURL[:i], "/") {
+		return "ssh://" + repoURL[:i] + "/" + repoURL[i+1:]
+	}
+
+	return repoURL
+}
+
+// urlHost returns the host of a repository URL, which can also be an scp-like SSH address.
+func urlHost(repoURL string) string {
+	u, err := url.Parse(normalizeURL(repoURL))
+	if err != nil {
+		return ""
+	}
+
+	return strings.ToLower(u.Hostname())
+}
+
+// sameRepoURL reports whether two URLs point to the same repository, ignoring credentials and protocols.
+func sameRepoURL(a, b string) bool {
+	aOwner, aName, errA := repoFullName(normalizeURL(a))
+	bOwner, bName, errB := repoFullName(normalizeURL(b))
+
+	return errA == nil && errB == nil && urlHost(a) == urlHost(b) &&
+		strings.EqualFold(aOwner, bOwner) && strings.EqualFold(aName, bName)
+}
+
+// knownHosts returns the hosts of the configured repositories and of the GitHub instance.
+func knownHosts(conf *Configuration) map[string]bool {
+	hosts := map[string]bool{"github.com": true}
+
+	if conf.BaseURL != "" {
+		hosts[urlHost(conf.BaseURL)] = true
+	}
+
+	for _, r := range conf.Repos {
+		hosts[urlHost(r.URL)] = true
+	}
+
+	return hosts
+}
+
+// adoptableRepo returns the repository cloned in a directory if its origin is on a known host.
+// The returned reason explains why the directory can't be adopted.
+func adoptableRepo(conf *Configuration, dir string) (repo Repo, reason string, err error) {
+	repository, err := git.PlainOpen(dir)
+	if errors.Is(err, git.ErrRepositoryNotExists) || errors.Is(err, syscall.ENOTDIR) {
+		return Repo{}, "not a git repository", nil
+	}
+
+	if err != nil {
+		return Repo{}, "", err
+	}
+
+	cloneURL, err := originURL(repository)
+	if errors.Is(err, errNoOrigin) {
+		return Repo{}, err.Error(), nil
+	}
+
+	if err != nil {
+		return Repo{}, "", err
+	}
+
+	if !knownHosts(conf)[urlHost(cloneURL)] {
+		return Repo{}, "unknown host " + urlHost(cloneURL), nil
+	}
+
+	for _, r := range conf.Repos {
+		if sameRepoURL(r.URL, cloneURL) {
+			return Repo{}, "already cloned in " + r.Dir, nil
+		}
+	}
+
+	if u, err := url.Parse(cloneURL); err == nil && u.User == nil {
+		cloneURL = authURL(conf, cloneURL)
+	}
+
+	rel, err := filepath.Rel(conf.BaseDir, dir)
+	if err != nil {
+		return Repo{}, "", err
+	}
+
+	return Repo{URL: cloneURL, Dir: conf.BaseDir + "/" + rel, Branch: defaultBranch(repository)}, "", nil
+}
+
+// defaultBranch returns the branch origin/HEAD points to, falling back to the checked out branch.
+func defaultBranch(repository *git.Repository) string {
+	ref, err := repository.Reference(plumbing.NewRemoteHEADReferenceName(git.DefaultRemoteName), false)
+	if err == nil && ref.Type() == plumbing.SymbolicReference {
+		return strings.TrimPrefix(ref.Target().String(), "refs/remotes/"+git.DefaultRemoteName+"/")
+	}
+
+	head, err := repository.Head()
+	if err == nil && head.Name().IsBranch() {
+		return head.Name().Short()
+	}
+
+	return ""
+}
+
+// unpushedWork returns the reason why deleting a file or directory would lose work,
+// which is empty if everything in it is pushed.
+func unpushedWork(path string) (string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+
+	if !info.IsDir() {
+		return "not a git repository", nil
+	}
+
+	repository, err := git.PlainOpen(path)
+	if errors.Is(err, git.ErrRepositoryNotExists) {
+		files, err := ioutil.ReadDir(path)
+		if err != nil || len(files) == 0 {
+			return "", err
+		}
+
+		return "not a git repository", nil
+	}
+
+	if err != nil {
+		return "", err
+	}
+
+	workTree, err := repository.Worktree()
+	if err != nil && !errors.Is(err, git.ErrIsBareRepository) {
+		return "", err
+	}
+
+	if err == nil {
+		repoStatus, err := worktreeStatus(repository, workTree)
+		if err != nil {
+			return "", err
+		}
+
+		if !repoStatus.IsClean() {
+			return "uncommitted changes", nil
+		}
+	}
+
+	_, err = repository.Reference(plumbing.ReferenceName("refs/stash"), false)
+	if err == nil {
+		return "stashed changes", nil
+	}
+
+	refs, err := repository.References()
+	if err != nil {
+		return "", err
+	}
+
+	var pushed []plumbing.Hash
+	var branches []*plumbing.Reference
+
+	err = refs.ForEach(func(ref *plumbing.Reference) error {
+		switch {
+		case ref.Type() != plumbing.HashReference:
+		case ref.Name().IsRemote():
+			pushed = append(pushed, ref.Hash())
+		case ref.Name().IsBranch():
+			branches = append(branches, ref)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	seen, err := ancestors(repository, pushed)
+	if err != nil {
+		return "", err
+	}
+
+	for _, b := range branches {
+		if !seen[b.Hash()] {
+			return "unpushed commits on " + b.Name().Short(), nil
+		}
+	}
+
+	return "", nil
+}
+
+// archivePath returns the path an untracked entry is moved to, which is
+// its path relative to the base directory inside the archive directory.
+func archivePath(conf *Configuration, archiveDir, path string) (string, error) {
+	rel, err := filepath.Rel(conf.BaseDir, path)
+	if err != nil {
+		return "", err
+	}
+
+	dest := filepath.Join(archiveDir, rel)
+	if pathExists(dest) {
+		dest += "-" + time.Now().Format(backupSuffixFormat)
+	}
+
+	return dest, nil
+}
+
+func runClean(conf *Configuration, opts cleanOptions) {
+	var status StatusList
+	var adopted bool
+
+	archiveDir := opts.archiveDir
+	if archiveDir == "" {
+		archiveDir = filepath.Join(conf.BaseDir, defaultArchiveDir)
+	}
+
+	absArchiveDir, err := filepath.Abs(archiveDir)
+	fatalIfError(err)
+
+	paths, err := untrackedPaths(conf)
+	fatalIfError(err)
+
+	for _, path := range paths {
+		if abs, err := filepath.Abs(path); err == nil && abs == absArchiveDir {
+			continue
+		}
+
+		if opts.adopt {
+			repo, reason, err := adoptableRepo(conf, path)
+			if err != nil {
+				status.appendError(path, err)
+
+				continue
+			}
+
+			if reason == "" {
+				conf.Repos = append(conf.Repos, repo)
+				adopted = !opts.dryRun
+
+				status.append(path, dryRunState(opts.dryRun, "adopted", "would adopt")+"\t"+redactURL(repo.URL))
+
+				continue
+			}
+
+			if !opts.delete && !opts.archive {
+				status.append(path, color.YellowString("not adopted: "+reason))
+
+				continue
+			}
+		}
+
+		if opts.delete {
+			reason, err := unpushedWork(path)
+			if err != nil {
+				status.appendError(path, err)
+
+				continue
+			}
+
+			if reason != "" {
+				status.append(path, color.YellowString("kept: "+reason))
+
+				continue
+			}
+
+			if !opts.dryRun {
+				err = os.RemoveAll(path)
+				if err != nil {
+					status.appendError(path, err)
+
+					continue
+				}
+			}
+
+			status.append(path, dryRunState(opts.dryRun, "deleted", "would delete"))
+
+			continue
+		}
+
+		dest, err := archivePath(conf, archiveDir, path)
+		if err != nil {
+			status.appendError(path, err)
+
+			continue
+		}
+
+		if !opts.dryRun {
+			err = os.MkdirAll(filepath.Dir(dest), 0o755)
+			if err == nil {
+				err = os.Rename(path, dest)
+			}
+
+			if err != nil {
+				status.appendError(path, err)
+
+				continue
+			}
+		}
+
+		status.append(path, dryRunState(opts.dryRun, "archived", "would archive")+"\t"+dest)
+	}
+
+	if len(status) == 0 {
+		fmt.Println("There are no untracked files or directories.")
+
+		return
+	}
+
+	status.print()
+
+	if adopted {
+		conf.save()
+	}
+}
+
+func dryRunState(dryRun bool, done, planned string) string {
+	if dryRun {
+		return color.YellowString(planned)
+	}
+
+	return color.GreenString(done)
+}
+
+// redactURL removes the credentials from a URL.
+func redactURL(repoURL string) string {
+	u, err := url.Parse(repoURL)
+	if err != nil || u.User == nil {
+		return repoURL
+	}
+
+	return u.Redacted()
+}
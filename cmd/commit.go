@@ -0,0 +1,118 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	color "github.com/fatih/color"
+	git "github.com/go-git/go-git/v5"
+	gitconfig "github.com/go-git/go-git/v5/config"
+	object "github.com/go-git/go-git/v5/plumbing/object"
+	cobra "github.com/spf13/cobra"
+)
+
+var (
+	errNothingToCommit = errors.New("nothing to commit")
+	errNoIdentity      = errors.New("user name and email are not configured, run pull or set them using git config")
+)
+
+func init() {
+	var message string
+	var all bool
+
+	commitCmd := &cobra.Command{
+		Use:         "commit",
+		Short:       "Commit the staged changes in all repositories",
+		Annotations: mutating,
+		Run: func(cmd *cobra.Command, args []string) {
+			repoLoop(func(ctx context.Context, conf *Configuration, repo Repo, status *StatusList) {
+				runCommit(ctx, conf, repo, status, message, all)
+			}, "Committing")
+		},
+	}
+
+	commitCmd.Flags().StringVarP(&message, "message", "m", "", "Commit message")
+	fatalIfError(commitCmd.MarkFlagRequired("message"))
+	commitCmd.Flags().BoolVarP(&all, "all", "a", false, "Commit all changes of the tracked files")
+	addGroupFlag(commitCmd)
+
+	rootCmd.AddCommand(commitCmd)
+}
+
+// hasChanges reports whether a commit of a worktree with the given status wouldn't be empty.
+func hasChanges(repoStatus git.Status, all bool) bool {
+	for _, s := range repoStatus {
+		if s.Staging != git.Unmodified && s.Staging != git.Untracked {
+			return true
+		}
+
+		if all && s.Worktree != git.Unmodified && s.Worktree != git.Untracked {
+			return true
+		}
+	}
+
+	return false
+}
+
+// commitAuthor returns the author of new commits from the configuration of a repository,
+// falling back to the global git configuration like git does.
+func commitAuthor(repository *git.Repository) (*object.Signature, error) {
+	repoConf, err := repository.Config()
+	if err != nil {
+		return nil, err
+	}
+
+	if repoConf.User.Name == "" || repoConf.User.Email == "" {
+		repoConf, err = repository.ConfigScoped(gitconfig.GlobalScope)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if repoConf.User.Name == "" || repoConf.User.Email == "" {
+		return nil, errNoIdentity
+	}
+
+	return &object.Signature{
+		Name:  repoConf.User.Name,
+		Email: repoConf.User.Email,
+		When:  time.Now(),
+	}, nil
+}
+
+func runCommit(ctx context.Context, conf *Configuration, repo Repo, status *StatusList, message string, all bool) {
+	if conf.Mirror {
+		status.appendError(repo.Dir, errMirrorWorkspace)
+
+		return
+	}
+
+	if !pathExists(repo.Dir) {
+		status.append(repo.Dir, color.RedString("absent"))
+
+		return
+	}
+
+	backend, err := conf.backend(repo)
+	if err != nil {
+		status.appendError(repo.Dir, err)
+
+		return
+	}
+
+	hash, err := backend.Commit(ctx, repo, message, all)
+	if errors.Is(err, errNothingToCommit) {
+		status.append(repo.Dir, color.YellowString("nothing to commit"))
+
+		return
+	}
+
+	if err != nil {
+		status.appendError(repo.Dir, err)
+
+		return
+	}
+
+	status.append(repo.Dir, color.GreenString("committed")+"\t"+hash.String()[:7])
+}
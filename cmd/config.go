@@ -6,31 +6,65 @@ import (
 	"io/ioutil"
 	"os"
 	"path"
+	"path/filepath"
 	"syscall"
 )
 
 const configFile = "gr.conf"
 
+// CloneOptions holds the options used when cloning and fetching repositories.
+type CloneOptions struct {
+	Depth        int    `json:"depth,omitempty"`
+	SingleBranch bool   `json:"singleBranch,omitempty"`
+	Filter       string `json:"filter,omitempty"`
+}
+
 // Repo holds a repository URL and its local directory equivalent.
 type Repo struct {
-	URL    string `json:"url"`
-	Dir    string `json:"dir"`
-	Branch string `json:"branch"`
-	Parent string `json:"parent"`
+	URL     string        `json:"url"`
+	Dir     string        `json:"dir"`
+	Branch  string        `json:"branch"`
+	Parent  string        `json:"parent"`
+	Clone   *CloneOptions `json:"clone,omitempty"`
+	SkipLFS bool          `json:"skipLfs,omitempty"`
+	Backend string        `json:"backend,omitempty"`
+	// SkipIdentity disables the management of the identity and signing configuration.
+	SkipIdentity bool `json:"skipIdentity,omitempty"`
+	// SkipHooks disables the installation of the shared hooks.
+	SkipHooks bool `json:"skipHooks,omitempty"`
+	// Pinned repositories were added using add and are kept on update even if they aren't discovered.
+	Pinned bool `json:"pinned,omitempty"`
+	// Tags are set using tags add, AutoTags are the topics and teams of the repository on GitHub.
+	Tags     []string `json:"tags,omitempty"`
+	AutoTags []string `json:"autoTags,omitempty"`
 }
 
 // Configuration holds git configuration data.
 type Configuration struct {
-	Fullname      string `json:"fullName"`
-	Username      string `json:"username"`
-	BaseDir       string `json:"baseDir"`
-	BaseURL       string `json:"baseUrl"`
-	Token         string `json:"token"`
-	Email         string `json:"email"`
-	Concurrency   uint   `json:"concurrency"`
-	SubDirs       bool   `json:"subDirs"`
-	ExcludedRepos string `json:"excludedRepos"`
-	Repos         []Repo `json:"repos"`
+	Version            int                 `json:"version"`
+	Fullname           string              `json:"fullName"`
+	Username           string              `json:"username"`
+	BaseDir            string              `json:"baseDir"`
+	BaseURL            string              `json:"baseUrl"`
+	Token              string              `json:"token"`
+	Email              string              `json:"email"`
+	NoReplyEmail       string              `json:"noreplyEmail"`
+	EmailPattern       string              `json:"emailPattern,omitempty"`
+	Concurrency        uint                `json:"concurrency"`
+	SubDirs            bool                `json:"subDirs"`
+	ExcludedRepos      string              `json:"excludedRepos"`
+	Clone              CloneOptions        `json:"clone"`
+	Mirror             bool                `json:"mirror"`
+	MirrorPullRequests bool                `json:"mirrorPullRequests"`
+	Backend            string              `json:"backend"`
+	Retries            uint                `json:"retries"`
+	Aliases            ConfigSource        `json:"aliases"`
+	Profile            Profile             `json:"profile"`
+	Identities         []IdentityRule      `json:"identities,omitempty"`
+	Hooks              HooksConfig         `json:"hooks"`
+	TeamTags           bool                `json:"teamTags,omitempty"`
+	Groups             map[string][]string `json:"groups,omitempty"`
+	Repos              []Repo              `json:"repos"`
 }
 
 func loadConfig() *Configuration {
@@ -42,7 +76,7 @@ func loadConfig() *Configuration {
 	for {
 		filePath := path.Join(cwd, configFile)
 
-		bytes, err := ioutil.ReadFile(filePath)
+		_, err := os.Stat(filePath)
 		e, ok := err.(*os.PathError)
 
 		if ok && e.Err == syscall.ENOENT {
@@ -59,20 +93,92 @@ func loadConfig() *Configuration {
 
 		fatalIfError(err)
 
-		err = json.Unmarshal(bytes, conf)
+		err = os.Chdir(cwd)
 		fatalIfError(err)
 
-		err = os.Chdir(cwd)
+		// The file is read while holding the lock, so that changes of other runs aren't lost
+		if lockRequired {
+			fatalIfError(lockWorkspace(waitForLock))
+		}
+
+		bytes, err := ioutil.ReadFile(configFile)
+		fatalIfError(err)
+
+		// Migrations write the file, so read-only commands take the lock too,
+		// waiting for other runs which may be migrating or saving it
+		if !lockRequired && needsUpgrade(bytes) {
+			fatalIfError(lockWorkspace(true))
+
+			bytes, err = ioutil.ReadFile(configFile)
+			fatalIfError(err)
+		}
+
+		bytes, err = upgradeConfig(bytes)
+		fatalIfError(err)
+
+		err = json.Unmarshal(bytes, conf)
 		fatalIfError(err)
 
 		return conf
 	}
 }
 
-func (conf *Configuration) save() {
+// cloneOptions returns the clone options of a repository,
+// falling back to the options of the workspace.
+func (conf *Configuration) cloneOptions(repo Repo) CloneOptions {
+	if repo.Clone != nil {
+		return *repo.Clone
+	}
+
+	return conf.Clone
+}
+
+// writeFileAtomic writes a file by renaming a temporary file, so that
+// the file is never left half-written, e.g. when gr is interrupted.
+func writeFileAtomic(name string, data []byte, perm os.FileMode) error {
+	f, err := ioutil.TempFile(filepath.Dir(name), "."+filepath.Base(name)+".tmp")
+	if err != nil {
+		return err
+	}
+
+	_, err = f.Write(data)
+	if err == nil {
+		err = f.Sync()
+	}
+
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err == nil {
+		err = os.Chmod(f.Name(), perm)
+	}
+
+	if err == nil {
+		err = os.Rename(f.Name(), name)
+	}
+
+	if err != nil {
+		_ = os.Remove(f.Name())
+	}
+
+	return err
+}
+
+// write saves the configuration.
+func (conf *Configuration) write() error {
+	conf.Version = configVersion
+
 	bytes, err := json.MarshalIndent(conf, "", "\t")
-	fatalIfError(err)
-	err = ioutil.WriteFile(configFile, bytes, 0o600)
+	if err != nil {
+		return err
+	}
+
+	return writeFileAtomic(configFile, bytes, 0o600)
+}
+
+func (conf *Configuration) save() {
+	err := conf.write()
 	fatalIfError(err)
 
 	fmt.Println("Configuration saved. You can now run pull to download/update your repositories.")
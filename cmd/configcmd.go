@@ -0,0 +1,556 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"os/exec"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	cobra "github.com/spf13/cobra"
+)
+
+const (
+	defaultEditor = "vi"
+	hiddenValue   = "********"
+)
+
+// configDefaults holds the values settings are reset to, if they aren't the zero value.
+var configDefaults = map[string]string{
+	"retries": strconv.Itoa(defaultRetries),
+}
+
+var (
+	errUnknownConfigKey = errors.New("unknown configuration key")
+	errInvalidValue     = errors.New("invalid value")
+	errInvalidConfig    = errors.New("invalid configuration")
+	errNotADirectory    = errors.New("not a directory")
+	errEmptyValue       = errors.New("must not be empty")
+	errDuplicateDir     = errors.New("directory is used by several repositories")
+)
+
+// configKey is a setting of the configuration, addressed by the JSON names of its fields
+// separated by dots, e.g. clone.depth. The entries of maps are addressed by their key,
+// e.g. groups.backend or profile.repoConfig.pull.rebase.
+type configKey struct {
+	// value is the field, or the map holding the entry
+	value reflect.Value
+	// mapKey is the key of the entry if value is a map
+	mapKey string
+}
+
+func init() {
+	configCmd := &cobra.Command{
+		Use:   "config",
+		Short: "Show and change the configuration",
+		Run: func(cmd *cobra.Command, args []string) {
+			err := cmd.Help()
+			fatalIfError(err)
+		},
+	}
+
+	configGetCmd := &cobra.Command{
+		Use:   "get KEY",
+		Short: "Show a setting, e.g. concurrency, clone.depth or groups.NAME",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			runConfigGet(loadConfig(), args[0])
+		},
+	}
+
+	configSetCmd := &cobra.Command{
+		Use:         "set KEY VALUE",
+		Short:       "Change a setting; lists are comma separated, other complex values are given as JSON",
+		Annotations: mutating,
+		Args:        cobra.ExactArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+			runConfigSet(loadConfig(), args[0], &args[1])
+		},
+	}
+
+	configUnsetCmd := &cobra.Command{
+		Use:         "unset KEY",
+		Short:       "Reset a setting to its default",
+		Annotations: mutating,
+		Args:        cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			runConfigSet(loadConfig(), args[0], nil)
+		},
+	}
+
+	configListCmd := &cobra.Command{
+		Use:   "list",
+		Short: "List all settings except the repositories",
+		Run: func(cmd *cobra.Command, args []string) {
+			runConfigList(loadConfig())
+		},
+	}
+
+	configValidateCmd := &cobra.Command{
+		Use:   "validate",
+		Short: "Check the configuration for errors",
+		Run: func(cmd *cobra.Command, args []string) {
+			runConfigValidate()
+		},
+	}
+
+	configEditCmd := &cobra.Command{
+		Use:         "edit",
+		Short:       "Edit the configuration using $VISUAL or $EDITOR",
+		Annotations: mutating,
+		Run: func(cmd *cobra.Command, args []string) {
+			runConfigEdit()
+		},
+	}
+
+	configCmd.AddCommand(configGetCmd, configSetCmd, configUnsetCmd, configListCmd, configValidateCmd, configEditCmd)
+	rootCmd.AddCommand(configCmd)
+}
+
+// jsonName returns the name of a field in JSON.
+func jsonName(field reflect.StructField) string {
+	name := strings.Split(field.Tag.Get("json"), ",")[0]
+	if name == "" {
+		return field.Name
+	}
+
+	return name
+}
+
+// structField returns the field of a struct with the given JSON name,
+// including the fields of embedded structs.
+func structField(v reflect.Value, name string) (reflect.Value, bool) {
+	t := v.Type()
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+
+		if f.Anonymous {
+			if fv, ok := structField(v.Field(i), name); ok {
+				return fv, true
+			}
+
+			continue
+		}
+
+		if strings.EqualFold(jsonName(f), name) {
+			return v.Field(i), true
+		}
+	}
+
+	return reflect.Value{}, false
+}
+
+func lookupConfigKey(conf *Configuration, key string) (configKey, error) {
+	v := reflect.ValueOf(conf).Elem()
+	parts := strings.Split(key, ".")
+
+	for i, p := range parts {
+		switch v.Kind() {
+		case reflect.Struct:
+			f, ok := structField(v, p)
+			if !ok {
+				return configKey{}, fmt.Errorf("%s: %w", key, errUnknownConfigKey)
+			}
+
+			v = f
+		case reflect.Map:
+			return configKey{value: v, mapKey: strings.Join(parts[i:], ".")}, nil
+		default:
+			return configKey{}, fmt.Errorf("%s: %w", key, errUnknownConfigKey)
+		}
+	}
+
+	return configKey{value: v}, nil
+}
+
+// parseConfigValue converts a string to a value of the given type.
+func parseConfigValue(t reflect.Type, s string) (reflect.Value, error) {
+	var err error
+
+	v := reflect.New(t).Elem()
+
+	switch t.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		var b bool
+
+		b, err = strconv.ParseBool(s)
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		var n int64
+
+		n, err = strconv.ParseInt(s, 10, t.Bits())
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		var n uint64
+
+		n, err = strconv.ParseUint(s, 10, t.Bits())
+		v.SetUint(n)
+	default:
+		if t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.String && !strings.HasPrefix(s, "[") {
+			for _, item := range strings.Split(s, ",") {
+				v = reflect.Append(v, reflect.ValueOf(strings.TrimSpace(item)))
+			}
+
+			return v, nil
+		}
+
+		err = json.Unmarshal([]byte(s), v.Addr().Interface())
+	}
+
+	if err != nil {
+		return reflect.Value{}, fmt.Errorf("%q is not a valid %s: %w", s, t, errInvalidValue)
+	}
+
+	return v, nil
+}
+
+// formatConfigValue returns the string representation of a value, which is JSON for complex values.
+func formatConfigValue(v reflect.Value) string {
+	switch v.Kind() {
+	case reflect.String:
+		return v.String()
+	case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return fmt.Sprint(v.Interface())
+	}
+
+	b, err := json.Marshal(v.Interface())
+	fatalIfError(err)
+
+	return string(b)
+}
+
+// configEntries adds the settings of a value to entries, keyed by their dotted key.
+func configEntries(prefix string, v reflect.Value, entries map[string]string) {
+	switch v.Kind() {
+	case reflect.Struct:
+		t := v.Type()
+
+		for i := 0; i < t.NumField(); i++ {
+			if t.Field(i).Anonymous {
+				configEntries(prefix, v.Field(i), entries)
+
+				continue
+			}
+
+			configEntries(prefix+jsonName(t.Field(i))+".", v.Field(i), entries)
+		}
+	case reflect.Map:
+		for _, k := range v.MapKeys() {
+			entries[prefix+k.String()] = formatConfigValue(v.MapIndex(k))
+		}
+	default:
+		if !v.IsZero() {
+			entries[strings.TrimSuffix(prefix, ".")] = formatConfigValue(v)
+		}
+	}
+}
+
+func runConfigGet(conf *Configuration, key string) {
+	k, err := lookupConfigKey(conf, key)
+	fatalIfError(err)
+
+	if k.value.Kind() != reflect.Map || k.mapKey == "" {
+		fmt.Println(formatConfigValue(k.value))
+
+		return
+	}
+
+	v := k.value.MapIndex(reflect.ValueOf(k.mapKey))
+	if !v.IsValid() {
+		fatalError(fmt.Errorf("%s: %w", key, errUnknownConfigKey))
+
+		return
+	}
+
+	fmt.Println(formatConfigValue(v))
+}
+
+// runConfigSet changes a setting, or resets it if value is nil.
+func runConfigSet(conf *Configuration, key string, value *string) {
+	k, err := lookupConfigKey(conf, key)
+	fatalIfError(err)
+
+	if d, ok := configDefaults[key]; ok && value == nil {
+		value = &d
+	}
+
+	if k.value.Kind() == reflect.Map && k.mapKey != "" {
+		if k.value.IsNil() {
+			k.value.Set(reflect.MakeMap(k.value.Type()))
+		}
+
+		mapKey := reflect.ValueOf(k.mapKey)
+
+		if value == nil {
+			k.value.SetMapIndex(mapKey, reflect.Value{})
+		} else {
+			v, err := parseConfigValue(k.value.Type().Elem(), *value)
+			fatalIfError(err)
+
+			k.value.SetMapIndex(mapKey, v)
+		}
+	} else {
+		v := reflect.Zero(k.value.Type())
+
+		if value != nil {
+			v, err = parseConfigValue(k.value.Type(), *value)
+			fatalIfError(err)
+		}
+
+		k.value.Set(v)
+	}
+
+	errs := conf.validate()
+	if len(errs) > 0 {
+		printErrors(errs)
+		fatalError(errInvalidConfig)
+
+		return
+	}
+
+	fatalIfError(conf.write())
+}
+
+func runConfigList(conf *Configuration) {
+	entries := make(map[string]string)
+
+	v := reflect.ValueOf(conf).Elem()
+	t := v.Type()
+
+	for i := 0; i < t.NumField(); i++ {
+		name := jsonName(t.Field(i))
+
+		if name != "repos" {
+			configEntries(name+".", v.Field(i), entries)
+		}
+	}
+
+	if entries["token"] != "" {
+		entries["token"] = hiddenValue
+	}
+
+	keys := make([]string, 0, len(entries))
+	for k := range entries {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		fmt.Println(k + "=" + entries[k])
+	}
+}
+
+// decodeConfig parses a configuration, rejecting unknown settings.
+func decodeConfig(data []byte) (*Configuration, error) {
+	var conf Configuration
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+
+	return &conf, dec.Decode(&conf)
+}
+
+func validateRegexp(name, expr string) error {
+	if expr == "" {
+		return nil
+	}
+
+	_, err := regexp.Compile(expr)
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+
+	return nil
+}
+
+func validateDir(name, dir string) error {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+
+	if !info.IsDir() {
+		return fmt.Errorf("%s: %s: %w", name, dir, errNotADirectory)
+	}
+
+	return nil
+}
+
+// validate returns the errors of the configuration.
+func (conf *Configuration) validate() []error {
+	var errs []error
+
+	check := func(err error) {
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if conf.Version != configVersion {
+		check(fmt.Errorf("version: %d: %w", conf.Version, errInvalidValue))
+	}
+
+	check(validateDir("baseDir", conf.BaseDir))
+	check(validateRegexp("excludedRepos", conf.ExcludedRepos))
+	check(validateRegexp("emailPattern", conf.EmailPattern))
+
+	if conf.BaseURL != "" {
+		u, err := url.Parse(conf.BaseURL)
+		if err == nil && (u.Scheme == "" || u.Host == "") {
+			err = fmt.Errorf("%s: %w", conf.BaseURL, errInvalidValue)
+		}
+
+		if err != nil {
+			check(fmt.Errorf("baseUrl: %w", err))
+		}
+	}
+
+	if conf.Clone.Depth < 0 {
+		check(fmt.Errorf("clone.depth: %d: %w", conf.Clone.Depth, errInvalidValue))
+	}
+
+	_, err := conf.backend(Repo{})
+	if err != nil {
+		check(fmt.Errorf("backend: %w", err))
+	}
+
+	if conf.Hooks.Dir != "" {
+		check(validateDir("hooks.dir", conf.Hooks.Dir))
+	}
+
+	for k := range conf.Profile.RepoConfig {
+		_, _, _, err := splitConfigKey(k)
+		if err != nil {
+			check(fmt.Errorf("profile.repoConfig: %w", err))
+		}
+	}
+
+	err = conf.Hooks.checkHooksPath(conf.Profile.RepoConfig)
+	if err != nil {
+		check(fmt.Errorf("profile.repoConfig: %w", err))
+	}
+
+	for i, rule := range conf.Identities {
+		check(validateRegexp(fmt.Sprintf("identities[%d].pattern", i), rule.Pattern))
+	}
+
+	dirs := make(map[string]bool, len(conf.Repos))
+
+	for i, r := range conf.Repos {
+		name := fmt.Sprintf("repos[%d]", i)
+
+		switch {
+		case r.URL == "":
+			check(fmt.Errorf("%s.url: %w", name, errEmptyValue))
+		case r.Dir == "":
+			check(fmt.Errorf("%s.dir: %w", name, errEmptyValue))
+		case dirs[r.Dir]:
+			check(fmt.Errorf("%s.dir: %s: %w", name, r.Dir, errDuplicateDir))
+		}
+
+		dirs[r.Dir] = true
+
+		_, err := conf.backend(r)
+		if err != nil {
+			check(fmt.Errorf("%s.backend: %w", name, err))
+		}
+	}
+
+	return errs
+}
+
+func printErrors(errs []error) {
+	for _, err := range errs {
+		fmt.Println(err)
+	}
+}
+
+func runConfigValidate() {
+	// Locate the configuration and change to its directory, which relative paths are based on
+	loadConfig()
+
+	data, err := ioutil.ReadFile(configFile)
+	fatalIfError(err)
+
+	conf, err := decodeConfig(data)
+	fatalIfError(err)
+
+	errs := conf.validate()
+	if len(errs) > 0 {
+		printErrors(errs)
+		fatalError(errInvalidConfig)
+
+		return
+	}
+
+	fmt.Println("Configuration is valid.")
+}
+
+func runConfigEdit() {
+	loadConfig()
+
+	data, err := ioutil.ReadFile(configFile)
+	fatalIfError(err)
+
+	tmp, err := ioutil.TempFile(".", "."+configFile+".edit*.json")
+	fatalIfError(err)
+
+	_, err = tmp.Write(data)
+	fatalIfError(err)
+	fatalIfError(tmp.Close())
+
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+
+	if editor == "" {
+		editor = defaultEditor
+	}
+
+	// The editor may be given with arguments, e.g. "code --wait"
+	args := append(strings.Fields(editor), tmp.Name())
+
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	err = cmd.Run()
+	fatalIfError(err)
+
+	edited, err := ioutil.ReadFile(tmp.Name())
+	fatalIfError(err)
+
+	conf, err := decodeConfig(edited)
+
+	var errs []error
+	if err != nil {
+		errs = []error{err}
+	} else {
+		errs = conf.validate()
+	}
+
+	if len(errs) > 0 {
+		printErrors(errs)
+		fatalError(fmt.Errorf("%w, the changes were kept in %s", errInvalidConfig, tmp.Name()))
+
+		return
+	}
+
+	fatalIfError(conf.write())
+	fatalIfError(os.Remove(tmp.Name()))
+}
@@ -0,0 +1,81 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"strconv"
+
+	color "github.com/fatih/color"
+	git "github.com/go-git/go-git/v5"
+	cobra "github.com/spf13/cobra"
+)
+
+func init() {
+	var depth int
+
+	deepenCmd := &cobra.Command{
+		Use:         "deepen",
+		Short:       "Deepen the history of all shallow repositories",
+		Annotations: mutating,
+		Run: func(cmd *cobra.Command, args []string) {
+			repoLoop(func(ctx context.Context, conf *Configuration, repo Repo, status *StatusList) {
+				runDeepen(ctx, conf, repo, status, depth)
+			}, "Deepening")
+		},
+	}
+
+	deepenCmd.Flags().IntVarP(&depth, "depth", "d", 0,
+		"Number of commits to add to the history (0 fetches the full history)")
+	addGroupFlag(deepenCmd)
+
+	rootCmd.AddCommand(deepenCmd)
+}
+
+func runDeepen(ctx context.Context, conf *Configuration, repo Repo, status *StatusList, depth int) {
+	if conf.Mirror {
+		status.appendError(repo.Dir, errMirrorWorkspace)
+
+		return
+	}
+
+	repository, err := git.PlainOpen(repo.Dir)
+	if errors.Is(err, git.ErrRepositoryNotExists) {
+		status.append(repo.Dir, color.RedString("absent"))
+
+		return
+	}
+
+	if err != nil {
+		status.appendError(repo.Dir, err)
+
+		return
+	}
+
+	shallows, err := repository.Storer.Shallow()
+	if err != nil {
+		status.appendError(repo.Dir, err)
+
+		return
+	}
+
+	if len(shallows) == 0 {
+		status.append(repo.Dir, color.GreenString("complete"))
+
+		return
+	}
+
+	// go-git can't deepen or unshallow existing repositories
+	arg := "--unshallow"
+	if depth > 0 {
+		arg = "--deepen=" + strconv.Itoa(depth)
+	}
+
+	err = execGit(ctx, repo.Dir, "fetch", arg)
+	if err != nil {
+		status.appendError(repo.Dir, err)
+
+		return
+	}
+
+	status.append(repo.Dir, color.GreenString("ok"))
+}
@@ -0,0 +1,170 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	github "github.com/google/go-github/github"
+	term "golang.org/x/term"
+)
+
+const noReplyDomain = "users.noreply.github.com"
+
+// noReplyHost returns the domain of the noreply addresses of the GitHub instance.
+func noReplyHost(conf *Configuration) string {
+	if conf.BaseURL != "" {
+		if u, err := url.Parse(conf.BaseURL); err == nil && u.Hostname() != "" {
+			return "users.noreply." + u.Hostname()
+		}
+	}
+
+	return noReplyDomain
+}
+
+// noReplyEmail returns the ID-based noreply address of a user, which GitHub
+// associates with the account even if the username changes.
+func noReplyEmail(conf *Configuration, usr *github.User) string {
+	return strconv.FormatInt(usr.GetID(), 10) + "+" + usr.GetLogin() + "@" + noReplyHost(conf)
+}
+
+// verifiedEmails returns the verified email addresses of the authenticated user, the primary
+// address first. No addresses are returned if the token isn't allowed to read them.
+func verifiedEmails(ctx context.Context, conf *Configuration, client *github.Client) ([]string, error) {
+	var emails []string
+
+	if conf.Token == "" {
+		return nil, nil
+	}
+
+	opts := &github.ListOptions{PerPage: 100}
+
+	for {
+		page, resp, err := client.Users.ListEmails(ctx, opts)
+
+		var errResp *github.ErrorResponse
+		// The token lacks the user:email scope
+		if errors.As(err, &errResp) && (errResp.Response.StatusCode == http.StatusForbidden ||
+			errResp.Response.StatusCode == http.StatusNotFound) {
+			return nil, nil
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		for _, e := range page {
+			switch {
+			case !e.GetVerified():
+			case e.GetPrimary():
+				emails = append([]string{e.GetEmail()}, emails...)
+			default:
+				emails = append(emails, e.GetEmail())
+			}
+		}
+
+		if resp.NextPage == 0 {
+			return emails, nil
+		}
+
+		opts.Page = resp.NextPage
+	}
+}
+
+// pickEmail asks the user to choose one of the given email addresses.
+func pickEmail(emails []string) string {
+	fmt.Println("Choose the email address used for commits:")
+
+	for i, e := range emails {
+		fmt.Printf("%d) %s\n", i+1, e)
+	}
+
+	reader := bufio.NewReader(os.Stdin)
+
+	for {
+		fmt.Printf("Email [1]: ")
+
+		line, err := reader.ReadString('\n')
+		fatalIfError(err)
+
+		line = strings.TrimSpace(line)
+		if line == "" {
+			return emails[0]
+		}
+
+		i, err := strconv.Atoi(line)
+		if err == nil && i >= 1 && i <= len(emails) {
+			return emails[i-1]
+		}
+	}
+}
+
+// containsFold reports whether list contains s, ignoring case.
+func containsFold(list []string, s string) bool {
+	for _, e := range list {
+		if strings.EqualFold(e, s) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// chooseEmail returns the email address used for commits, which is, in order of preference,
+// the address given explicitly, the first verified address matching the email pattern,
+// the address already set in the configuration if it is still valid, the address chosen
+// by the user, the public address of the profile, the primary verified address and the
+// noreply address.
+func chooseEmail(ctx context.Context, conf *Configuration, client *github.Client, usr *github.User,
+	explicit bool) (string, error) {
+	if explicit {
+		return conf.Email, nil
+	}
+
+	emails, err := verifiedEmails(ctx, conf, client)
+	if err != nil {
+		return "", err
+	}
+
+	if conf.EmailPattern != "" {
+		re, err := regexp.Compile(conf.EmailPattern)
+		if err != nil {
+			return "", err
+		}
+
+		for _, e := range emails {
+			if re.MatchString(e) {
+				return e, nil
+			}
+		}
+	}
+
+	// Older versions used the username-based noreply address when the profile had no public address
+	legacyNoReply := usr.GetLogin() + "@" + noReplyHost(conf)
+
+	// The verified addresses can't be checked if the token isn't allowed to read them
+	if conf.Email != "" && !strings.EqualFold(conf.Email, legacyNoReply) && (len(emails) == 0 ||
+		containsFold(emails, conf.Email) || strings.EqualFold(conf.Email, conf.NoReplyEmail)) {
+		return conf.Email, nil
+	}
+
+	if len(emails) > 1 && term.IsTerminal(int(os.Stdin.Fd())) {
+		return pickEmail(append(emails, conf.NoReplyEmail)), nil
+	}
+
+	switch {
+	case usr.GetEmail() != "":
+		return usr.GetEmail(), nil
+	case len(emails) > 0:
+		return emails[0], nil
+	}
+
+	return conf.NoReplyEmail, nil
+}
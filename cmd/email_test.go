@@ -0,0 +1,91 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"testing"
+
+	github "github.com/google/go-github/github"
+)
+
+// newEmailsClient returns a client of a GitHub API serving the given email addresses of the user.
+func newEmailsClient(t *testing.T, emails []*github.UserEmail) *github.Client {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/user/emails" {
+			http.NotFound(w, r)
+
+			return
+		}
+
+		_ = json.NewEncoder(w).Encode(emails)
+	}))
+	t.Cleanup(server.Close)
+
+	client := github.NewClient(nil)
+
+	baseURL, err := url.Parse(server.URL + "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client.BaseURL = baseURL
+
+	return client
+}
+
+func TestChooseEmail(t *testing.T) {
+	// Don't ask to pick an address when the tests run in a terminal
+	stdin := os.Stdin
+	t.Cleanup(func() { os.Stdin = stdin })
+
+	devNull, err := os.Open(os.DevNull)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer devNull.Close()
+
+	os.Stdin = devNull
+
+	verified := true
+	primary := true
+	client := newEmailsClient(t, []*github.UserEmail{
+		{Email: github.String("me@home.org"), Verified: &verified, Primary: &primary},
+		{Email: github.String("me@corp.com"), Verified: &verified},
+	})
+	usr := &github.User{Login: github.String("bob"), ID: github.Int64(42)}
+	noReply := "42+bob@users.noreply.github.com"
+
+	tests := []struct {
+		name     string
+		email    string
+		pattern  string
+		explicit bool
+		want     string
+	}{
+		{"init", "", "", false, "me@home.org"},
+		{"init with pattern", "", "@corp\\.com$", false, "me@corp.com"},
+		{"explicit", "other@example.com", "@corp\\.com$", true, "other@example.com"},
+		{"update keeps a verified address", "me@corp.com", "", false, "me@corp.com"},
+		{"update keeps the noreply address", noReply, "", false, noReply},
+		{"update with a new pattern", "me@home.org", "@corp\\.com$", false, "me@corp.com"},
+		{"update with a pattern matching nothing", "me@corp.com", "@example\\.com$", false, "me@corp.com"},
+		{"update of a removed address", "old@example.com", "", false, "me@home.org"},
+		{"update of the legacy noreply address", "bob@users.noreply.github.com", "", false, "me@home.org"},
+	}
+
+	for _, tt := range tests {
+		conf := &Configuration{Token: "token", Email: tt.email, EmailPattern: tt.pattern, NoReplyEmail: noReply}
+
+		got, err := chooseEmail(context.Background(), conf, client, usr, tt.explicit)
+		if err != nil || got != tt.want {
+			t.Errorf("%s: chooseEmail = %q, %v, want %q", tt.name, got, err, tt.want)
+		}
+	}
+}
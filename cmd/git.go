@@ -0,0 +1,84 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// gitWaitDelay is the time to wait for the output of a killed git command to be closed.
+const gitWaitDelay = 2 * time.Second
+
+// gitError holds the error of a git command along with its output.
+type gitError struct {
+	command string
+	output  string
+	err     error
+}
+
+func (e *gitError) Error() string {
+	return fmt.Sprintf("git %s: %s: %s", e.command, e.err, e.output)
+}
+
+func (e *gitError) Unwrap() error {
+	return e.err
+}
+
+// gitCommand returns a command running the git executable with the given arguments in dir.
+// The command is killed when ctx is done.
+func gitCommand(ctx context.Context, dir string, args ...string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = dir
+	// Fail instead of waiting for credentials which will never be entered
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	// Keep interrupts from the terminal away from git, it is only killed when ctx is done,
+	// along with the processes it started, e.g. hooks, aliases and credential helpers
+	detachProcessGroup(cmd)
+	// Don't wait for processes which inherited the output pipes and survived the kill
+	cmd.WaitDelay = gitWaitDelay
+
+	return cmd
+}
+
+// outputGitCommand runs a git command created with ctx and returns its standard output.
+func outputGitCommand(ctx context.Context, cmd *exec.Cmd) (string, error) {
+	var stdout, stderr bytes.Buffer
+
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	err := cmd.Run()
+	if err != nil && ctx.Err() != nil {
+		// The command was killed because the operation timed out or was cancelled
+		return "", ctx.Err()
+	}
+
+	if err != nil {
+		return "", &gitError{
+			command: cmd.Args[1],
+			// Keep the output on a single line, so that it fits in the status list
+			output: strings.Join(strings.Fields(stderr.String()+" "+stdout.String()), " "),
+			err:    err,
+		}
+	}
+
+	return stdout.String(), nil
+}
+
+func runGitCommand(ctx context.Context, cmd *exec.Cmd) error {
+	_, err := outputGitCommand(ctx, cmd)
+
+	return err
+}
+
+func execGit(ctx context.Context, dir string, args ...string) error {
+	return runGitCommand(ctx, gitCommand(ctx, dir, args...))
+}
+
+func outputGit(ctx context.Context, dir string, args ...string) (string, error) {
+	return outputGitCommand(ctx, gitCommand(ctx, dir, args...))
+}
@@ -0,0 +1,36 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"testing"
+	"time"
+)
+
+// TestGitCommandTimeout checks that the processes started by git are killed
+// along with git, so that a command returns once its context is done.
+func TestGitCommandTimeout(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the alias uses sleep")
+	}
+
+	setupGit(t)
+
+	dir := t.TempDir()
+	runGit(t, dir, "config", "--global", "alias.slow", "!sleep 20")
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	start := time.Now()
+	err := execGit(ctx, dir, "slow")
+
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("execGit = %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	if d := time.Since(start); d > time.Second+gitWaitDelay {
+		t.Errorf("execGit returned after %s", d)
+	}
+}
@@ -0,0 +1,19 @@
+//go:build !windows
+// +build !windows
+
+package cmd
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// detachProcessGroup runs a command in its own process group, so that it doesn't receive
+// the signals sent to the process group of gr, e.g. SIGINT when pressing Ctrl-C.
+// When the context of the command is done, the whole group is killed.
+func detachProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+}
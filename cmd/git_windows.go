@@ -0,0 +1,25 @@
+//go:build windows
+// +build windows
+
+package cmd
+
+import (
+	"os/exec"
+	"strconv"
+	"syscall"
+)
+
+// detachProcessGroup runs a command in a new process group, so that it doesn't receive
+// the Ctrl-C events of the console of gr. When the context of the command is done,
+// the command is killed along with the processes it started.
+func detachProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{CreationFlags: syscall.CREATE_NEW_PROCESS_GROUP}
+	cmd.Cancel = func() error {
+		err := exec.Command("taskkill", "/T", "/F", "/PID", strconv.Itoa(cmd.Process.Pid)).Run()
+		if err != nil {
+			return cmd.Process.Kill()
+		}
+
+		return nil
+	}
+}
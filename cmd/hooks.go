@@ -0,0 +1,471 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	color "github.com/fatih/color"
+	git "github.com/go-git/go-git/v5"
+	formatconfig "github.com/go-git/go-git/v5/plumbing/format/config"
+	cobra "github.com/spf13/cobra"
+)
+
+const (
+	hooksCacheDir      = ".gr.hooks"
+	defaultHooksPath   = "hooks"
+	managedHooksOption = "managedHooksPath"
+	managedHookOption  = "managedHook"
+)
+
+var (
+	errHooksNotSynced    = errors.New("hooks directory doesn't exist, run hooks sync")
+	errHooksPathConflict = errors.New("core.hooksPath is set by gr to the shared hooks, " +
+		"remove it from the profile or use hooks sync --install")
+)
+
+// commitHooks are the hooks which are run by git when committing.
+var commitHooks = []string{"pre-commit", "prepare-commit-msg", "commit-msg", "post-commit"}
+
+// HooksConfig holds the source of the git hooks shared by all repositories.
+type HooksConfig struct {
+	// ConfigSource is the repository and the directory in it holding the hooks, which are downloaded by hooks sync.
+	ConfigSource
+	// Dir is a local directory holding the hooks, which is used instead of a repository.
+	Dir string `json:"dir,omitempty"`
+	// Install copies the hooks into each repository instead of setting core.hooksPath.
+	Install bool `json:"install,omitempty"`
+}
+
+func (hooks *HooksConfig) enabled() bool {
+	return hooks.Dir != "" || hooks.Repo != ""
+}
+
+// checkHooksPath returns an error if the repository options of the profile set
+// core.hooksPath, which gr manages itself unless the hooks are installed.
+func (hooks *HooksConfig) checkHooksPath(repoConfig map[string]string) error {
+	if !hooks.enabled() || hooks.Install {
+		return nil
+	}
+
+	key, ok := hooksPathKey(repoConfig)
+	if ok {
+		return fmt.Errorf("%s: %w", key, errHooksPathConflict)
+	}
+
+	return nil
+}
+
+// hooksPathKey returns the key of the options setting core.hooksPath, whose case may differ.
+func hooksPathKey(options map[string]string) (string, bool) {
+	for k := range options {
+		if strings.EqualFold(k, "core.hooksPath") {
+			return k, true
+		}
+	}
+
+	return "", false
+}
+
+// dir returns the absolute path of the directory holding the hooks.
+func (hooks *HooksConfig) dir() (string, error) {
+	if hooks.Dir != "" {
+		return filepath.Abs(hooks.Dir)
+	}
+
+	return filepath.Abs(hooksCacheDir)
+}
+
+func init() {
+	var source HooksConfig
+	var install bool
+
+	hooksCmd := &cobra.Command{
+		Use:   "hooks",
+		Short: "Manage the git hooks shared by all repositories",
+		Run: func(cmd *cobra.Command, args []string) {
+			err := cmd.Help()
+			fatalIfError(err)
+		},
+	}
+
+	hooksSyncCmd := &cobra.Command{
+		Use:         "sync",
+		Short:       "Download the shared git hooks and install them in all repositories",
+		Annotations: mutating,
+		Run: func(cmd *cobra.Command, args []string) {
+			conf := loadConfig()
+
+			changed := cmd.Flags().Changed("install")
+			if changed {
+				conf.Hooks.Install = install
+			}
+
+			if source.Repo != "" || source.Dir != "" {
+				conf.Hooks.ConfigSource = source.ConfigSource
+				conf.Hooks.Dir = source.Dir
+				changed = true
+			}
+
+			// A path alone changes the directory in the configured repository
+			if cmd.Flags().Changed("path") {
+				conf.Hooks.File = source.File
+				changed = true
+			}
+
+			if changed {
+				fatalIfError(conf.Hooks.checkHooksPath(conf.Profile.RepoConfig))
+				conf.save()
+			}
+
+			runHooksSync(conf)
+		},
+	}
+
+	hooksSyncCmd.Flags().StringVar(&source.Repo, "repo", "", "Repository containing the hooks, as OWNER/NAME or NAME")
+	hooksSyncCmd.Flags().StringVar(&source.File, "path", "", "Directory of the hooks in the repository (default: "+defaultHooksPath+")")
+	hooksSyncCmd.Flags().StringVar(&source.Dir, "dir", "", "Local directory containing the hooks, used instead of a repository")
+	hooksSyncCmd.Flags().BoolVar(&install, "install", false, "Copy the hooks into each repository instead of setting core.hooksPath")
+	addGroupFlag(hooksSyncCmd)
+
+	hooksCmd.AddCommand(hooksSyncCmd)
+	rootCmd.AddCommand(hooksCmd)
+}
+
+// fetchSourceDir returns the files stored in a directory of a repository on GitHub.
+func fetchSourceDir(conf *Configuration, source ConfigSource, defaultDir string) (map[string][]byte, error) {
+	ctx := context.Background()
+	owner, name := sourceRepo(conf, source)
+
+	dir := defaultDir
+	if source.File != "" {
+		dir = source.File
+	}
+
+	client := newGithubClient(conf)
+
+	_, entries, _, err := client.Repositories.GetContents(ctx, owner, name, dir, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	files := make(map[string][]byte, len(entries))
+
+	for _, e := range entries {
+		if e.GetType() != "file" {
+			continue
+		}
+
+		content, _, _, err := client.Repositories.GetContents(ctx, owner, name, e.GetPath(), nil)
+		if err != nil {
+			return nil, err
+		}
+
+		decoded, err := content.GetContent()
+		if err != nil {
+			return nil, err
+		}
+
+		files[e.GetName()] = []byte(decoded)
+	}
+
+	return files, nil
+}
+
+// downloadHooks replaces the hooks in the hooks cache directory with the ones stored on GitHub.
+func downloadHooks(conf *Configuration) error {
+	files, err := fetchSourceDir(conf, conf.Hooks.ConfigSource, defaultHooksPath)
+	if err != nil {
+		return err
+	}
+
+	tmpDir := hooksCacheDir + ".tmp"
+
+	err = os.RemoveAll(tmpDir)
+	if err != nil {
+		return err
+	}
+
+	err = os.Mkdir(tmpDir, 0o755)
+	if err != nil {
+		return err
+	}
+
+	for name, content := range files {
+		err = ioutil.WriteFile(filepath.Join(tmpDir, name), content, 0o755)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = os.RemoveAll(hooksCacheDir)
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmpDir, hooksCacheDir)
+}
+
+// hookFiles returns the names of the hooks in a directory.
+func hookFiles(dir string) ([]string, error) {
+	var hooks []string
+
+	entries, err := ioutil.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("%s: %w", dir, errHooksNotSynced)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, e := range entries {
+		if e.Mode().IsRegular() && !strings.HasPrefix(e.Name(), ".") {
+			hooks = append(hooks, e.Name())
+		}
+	}
+
+	return hooks, nil
+}
+
+func repoHooksDir(repo Repo) string {
+	return filepath.Join(repo.Dir, git.GitDirName, "hooks")
+}
+
+// installHook copies a hook into a repository. A different hook which isn't managed by gr is backed up.
+func installHook(src, dst string, managed bool) error {
+	content, err := ioutil.ReadFile(src)
+	if err != nil {
+		return err
+	}
+
+	old, err := ioutil.ReadFile(dst)
+	if err == nil && bytes.Equal(old, content) {
+		return nil
+	}
+
+	if err == nil && !managed {
+		err = ioutil.WriteFile(dst+gitConfigBackupExt, old, 0o755)
+	}
+
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Dir(dst), 0o755)
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(dst, content, 0o755)
+}
+
+// updateHooks installs the shared hooks in a repository, either by setting core.hooksPath or by copying
+// them into the repository. The hooks previously installed by gr are removed if they no longer exist.
+func updateHooks(conf *Configuration, repo Repo, cfg *formatconfig.Config) error {
+	var options []configOption
+	var installed []string
+
+	grSection := cfg.Section(grConfigSection)
+	managed := make(map[string]bool)
+
+	for _, h := range grSection.OptionAll(managedHookOption) {
+		managed[h] = true
+	}
+
+	if conf.Hooks.enabled() && !repo.SkipHooks {
+		dir, err := conf.Hooks.dir()
+		if err != nil {
+			return err
+		}
+
+		hooks, err := hookFiles(dir)
+		if err != nil {
+			return err
+		}
+
+		if conf.Hooks.Install {
+			for _, h := range hooks {
+				err = installHook(filepath.Join(dir, h), filepath.Join(repoHooksDir(repo), h), managed[h])
+				if err != nil {
+					return err
+				}
+
+				installed = append(installed, h)
+				delete(managed, h)
+			}
+		} else {
+			options = append(options, configOption{Key: "core.hooksPath", Value: dir})
+		}
+	}
+
+	for h := range managed {
+		err := os.Remove(filepath.Join(repoHooksDir(repo), h))
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	grSection.RemoveOption(managedHookOption)
+
+	for _, h := range installed {
+		grSection.AddOption(managedHookOption, h)
+	}
+
+	// Unless gr points core.hooksPath to the shared hooks, the option belongs to the profile if it sets it
+	if _, ok := hooksPathKey(conf.Profile.RepoConfig); ok && len(options) == 0 {
+		grSection.RemoveOption(managedHooksOption)
+	}
+
+	_, err := applyConfigOptions(cfg, options, managedHooksOption)
+
+	return err
+}
+
+// hooksState returns the state of the shared hooks in a repository,
+// which is empty if no hooks are configured for the repository.
+func hooksState(conf *Configuration, repo Repo, cfg *formatconfig.Config) (string, error) {
+	if !conf.Hooks.enabled() || repo.SkipHooks {
+		return "", nil
+	}
+
+	dir, err := conf.Hooks.dir()
+	if err != nil {
+		return "", err
+	}
+
+	if !conf.Hooks.Install {
+		hooksPath := cfg.Section("core").Option("hooksPath")
+
+		switch {
+		case hooksPath == dir && pathExists(dir):
+			return color.GreenString("hooks current"), nil
+		case hooksPath == "":
+			return color.RedString("hooks missing"), nil
+		default:
+			return color.YellowString("hooks outdated"), nil
+		}
+	}
+
+	hooks, err := hookFiles(dir)
+	if err != nil {
+		return "", err
+	}
+
+	state := color.GreenString("hooks current")
+
+	for _, h := range hooks {
+		content, err := ioutil.ReadFile(filepath.Join(dir, h))
+		if err != nil {
+			return "", err
+		}
+
+		installed, err := ioutil.ReadFile(filepath.Join(repoHooksDir(repo), h))
+		if os.IsNotExist(err) {
+			return color.RedString("hooks missing"), nil
+		}
+
+		if err != nil {
+			return "", err
+		}
+
+		if !bytes.Equal(content, installed) {
+			state = color.YellowString("hooks outdated")
+		}
+	}
+
+	return state, nil
+}
+
+// hasCommitHooks reports whether git would run any hooks when committing in a repository.
+func hasCommitHooks(repo Repo, cfg *formatconfig.Config) bool {
+	dir := cfg.Section("core").Option("hooksPath")
+	if dir == "" {
+		dir = repoHooksDir(repo)
+	} else if !filepath.IsAbs(dir) {
+		dir = filepath.Join(repo.Dir, dir)
+	}
+
+	for _, h := range commitHooks {
+		info, err := os.Stat(filepath.Join(dir, h))
+		if err == nil && info.Mode()&0o111 != 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
+func runHooksSync(conf *Configuration) {
+	if !conf.Hooks.enabled() {
+		fmt.Println("No hooks are configured, use --repo or --dir to set their source.")
+
+		return
+	}
+
+	if conf.Hooks.Dir == "" {
+		fatalIfError(downloadHooks(conf))
+	}
+
+	repoLoop(runHooksInstall, "Installing hooks")
+}
+
+func runHooksInstall(ctx context.Context, conf *Configuration, repo Repo, status *StatusList) {
+	if conf.Mirror {
+		status.appendError(repo.Dir, errMirrorWorkspace)
+
+		return
+	}
+
+	if !pathExists(repo.Dir) {
+		status.append(repo.Dir, color.RedString("absent"))
+
+		return
+	}
+
+	repository, err := git.PlainOpen(repo.Dir)
+	if err != nil {
+		status.appendError(repo.Dir, err)
+
+		return
+	}
+
+	repoConf, err := repository.Config()
+	if err != nil {
+		status.appendError(repo.Dir, err)
+
+		return
+	}
+
+	err = updateHooks(conf, repo, repoConf.Raw)
+	if err != nil {
+		status.appendError(repo.Dir, err)
+
+		return
+	}
+
+	err = repository.Storer.SetConfig(repoConf)
+	if err != nil {
+		status.appendError(repo.Dir, err)
+
+		return
+	}
+
+	state, err := hooksState(conf, repo, repoConf.Raw)
+	if err != nil {
+		status.appendError(repo.Dir, err)
+
+		return
+	}
+
+	if state == "" {
+		state = color.YellowString("skipped")
+	}
+
+	status.append(repo.Dir, state)
+}
@@ -0,0 +1,59 @@
+package cmd
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	formatconfig "github.com/go-git/go-git/v5/plumbing/format/config"
+)
+
+func TestHooksPathConflict(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "pre-commit"), "#!/bin/sh\n")
+
+	conf := &Configuration{Hooks: HooksConfig{Dir: dir}}
+	conf.Profile.RepoConfig = map[string]string{"core.hookspath": "/profile/hooks"}
+
+	err := conf.Hooks.checkHooksPath(conf.Profile.RepoConfig)
+	if !errors.Is(err, errHooksPathConflict) {
+		t.Errorf("checkHooksPath without --install: got %v, want %v", err, errHooksPathConflict)
+	}
+
+	conf.Hooks.Install = true
+
+	err = conf.Hooks.checkHooksPath(conf.Profile.RepoConfig)
+	if err != nil {
+		t.Errorf("checkHooksPath with --install: %v", err)
+	}
+
+	// Switch a repository using core.hooksPath to installed hooks and a profile setting the option
+	repo := Repo{Dir: t.TempDir()}
+	cfg := formatconfig.New()
+
+	err = setConfigOption(cfg, "core.hooksPath", dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg.Section(grConfigSection).AddOption(managedHooksOption, "core.hooksPath")
+
+	_, err = applyConfigOptions(cfg, configOptions(conf.Profile.RepoConfig), managedConfigOption)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = updateHooks(conf, repo, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	value, _, err := getConfigOption(cfg, "core.hooksPath")
+	if err != nil || value != "/profile/hooks" {
+		t.Errorf("core.hooksPath = %q, %v, want the value of the profile", value, err)
+	}
+
+	if !pathExists(filepath.Join(repoHooksDir(repo), "pre-commit")) {
+		t.Error("pre-commit hook not installed")
+	}
+}
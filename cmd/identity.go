@@ -0,0 +1,102 @@
+package cmd
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+const managedIdentityOption = "managedIdentity"
+
+// IdentityRule holds the identity and signing configuration of the repositories matching it.
+// A repository matches if it matches all of the given owner, host and pattern.
+type IdentityRule struct {
+	// Owner is the user or organization owning the repository.
+	Owner string `json:"owner,omitempty"`
+	// Host is the host name of the repository URL.
+	Host string `json:"host,omitempty"`
+	// Pattern is a regular expression matched against the full name (OWNER/NAME) of the repository.
+	Pattern string `json:"pattern,omitempty"`
+
+	Name  string `json:"name,omitempty"`
+	Email string `json:"email,omitempty"`
+	// SigningKey is the GPG key ID or the path of the SSH key used to sign commits and tags.
+	SigningKey string `json:"signingKey,omitempty"`
+	// SigningFormat is the format of the signing key, openpgp (the default) or ssh.
+	SigningFormat string `json:"signingFormat,omitempty"`
+}
+
+// matches reports whether a repository matches the rule.
+func (rule *IdentityRule) matches(repo Repo) (bool, error) {
+	owner, name, _ := repoFullName(repo.URL)
+
+	if rule.Owner != "" && !strings.EqualFold(rule.Owner, owner) {
+		return false, nil
+	}
+
+	if rule.Host != "" {
+		u, err := url.Parse(repo.URL)
+		if err != nil || !strings.EqualFold(rule.Host, u.Hostname()) {
+			return false, nil
+		}
+	}
+
+	if rule.Pattern != "" {
+		re, err := regexp.Compile(rule.Pattern)
+		if err != nil {
+			return false, err
+		}
+
+		return re.MatchString(owner + "/" + name), nil
+	}
+
+	return true, nil
+}
+
+// identityOptions returns the git configuration options holding the identity of a repository,
+// which is set by the first matching identity rule and falls back to the identity of the workspace.
+func (conf *Configuration) identityOptions(repo Repo) ([]configOption, error) {
+	rule := IdentityRule{Name: conf.Fullname, Email: conf.Email}
+
+	for _, r := range conf.Identities {
+		ok, err := r.matches(repo)
+		if err != nil {
+			return nil, err
+		}
+
+		if !ok {
+			continue
+		}
+
+		if r.Name != "" {
+			rule.Name = r.Name
+		}
+
+		if r.Email != "" {
+			rule.Email = r.Email
+		}
+
+		rule.SigningKey = r.SigningKey
+		rule.SigningFormat = r.SigningFormat
+
+		break
+	}
+
+	options := []configOption{
+		{Key: "user.name", Value: rule.Name},
+		{Key: "user.email", Value: rule.Email},
+	}
+
+	if rule.SigningKey != "" {
+		options = append(options,
+			configOption{Key: "user.signingKey", Value: rule.SigningKey},
+			configOption{Key: "commit.gpgSign", Value: "true"},
+			configOption{Key: "tag.gpgSign", Value: "true"})
+	}
+
+	if rule.SigningFormat != "" {
+		options = append(options, configOption{Key: "gpg.format", Value: rule.SigningFormat})
+	}
+
+	return options, nil
+}
@@ -2,39 +2,25 @@ package cmd
 
 import (
 	"context"
-	"encoding/base64"
-	"encoding/json"
-	"io/ioutil"
 	"net/http"
 	"net/url"
-	"os/user"
 	"regexp"
 	"strings"
 
-	gitconfig "github.com/go-git/go-git/v5/config"
 	github "github.com/google/go-github/github"
 	cobra "github.com/spf13/cobra"
 	oauth2 "golang.org/x/oauth2"
 )
 
-const (
-	gitAliasesRepo = "gr-git-aliases"
-	gitAliasesFile = "aliases.json"
-)
-
-type gitAlias struct {
-	Alias   string `json:"alias"`
-	Command string `json:"command"`
-}
-
 func init() {
 	if cFlags == nil {
 		cFlags = &Configuration{}
 	}
 
 	initCmd := &cobra.Command{
-		Use:   "init",
-		Short: "Initialize repository mirror",
+		Use:         "init",
+		Short:       "Initialize repository mirror",
+		Annotations: mutating,
 		Run: func(cmd *cobra.Command, args []string) {
 			runInit(cFlags, false)
 		},
@@ -47,6 +33,17 @@ func init() {
 	initCmd.Flags().StringVarP(&cFlags.BaseDir, "dir", "d", ".", "Directory in which repositories will be stored")
 	initCmd.Flags().BoolVarP(&cFlags.SubDirs, "subdirs", "s", false, "Enable creation of separate subdirectories for each org/user")
 	initCmd.Flags().StringVarP(&cFlags.ExcludedRepos, "exclude", "e", "", "Regular expression of repositories to exclude")
+	initCmd.Flags().IntVar(&cFlags.Clone.Depth, "depth", 0, "Create shallow clones with the specified number of commits")
+	initCmd.Flags().BoolVar(&cFlags.Clone.SingleBranch, "single-branch", false, "Clone only the default branch of each repository")
+	initCmd.Flags().StringVar(&cFlags.Clone.Filter, "filter", "", "Create partial clones using the specified filter (e.g. blob:none)")
+	initCmd.Flags().BoolVarP(&cFlags.Mirror, "mirror", "m", false, "Maintain bare mirrors of all repositories instead of working trees")
+	initCmd.Flags().BoolVar(&cFlags.MirrorPullRequests, "mirror-pulls", false, "Include pull request refs in mirrors")
+	initCmd.Flags().StringVarP(&cFlags.Backend, "backend", "b", backendGoGit, "Git backend to use (go-git or git)")
+	initCmd.Flags().StringVar(&cFlags.Email, "email", "", "Email address used for commits (default: chosen from the verified addresses)")
+	initCmd.Flags().StringVar(&cFlags.EmailPattern, "email-pattern", "",
+		"Regular expression choosing the email address used for commits from the verified addresses")
+	initCmd.Flags().BoolVar(&cFlags.TeamTags, "team-tags", false, "Tag repositories with the teams of the user having access to them")
+	initCmd.Flags().UintVar(&cFlags.Retries, "retries", defaultRetries, "Number of retries of clones and fetches failing due to network errors")
 
 	rootCmd.AddCommand(initCmd)
 }
@@ -82,37 +79,6 @@ func newGithubClient(conf *Configuration) *github.Client {
 	return client
 }
 
-func addGitAliases(ctx context.Context, conf *Configuration, client *github.Client) {
-	var ga []gitAlias
-
-	aliasesContent, _, _, err := client.Repositories.GetContents(ctx, conf.Username, gitAliasesRepo, gitAliasesFile, nil)
-	fatalIfError(err)
-	aliasesBytes, err := base64.StdEncoding.DecodeString(*aliasesContent.Content)
-	fatalIfError(err)
-	fatalIfError(json.Unmarshal(aliasesBytes, &ga))
-
-	cfg := gitconfig.NewConfig()
-	usr, err := user.Current()
-	fatalIfError(err)
-
-	gitconfigPath := usr.HomeDir + "/.gitconfig"
-	b, err := ioutil.ReadFile(gitconfigPath)
-	fatalIfError(err)
-
-	fatalIfError(cfg.Unmarshal(b))
-
-	section := cfg.Raw.Section("alias")
-	for _, alias := range ga {
-		section.SetOption(alias.Alias, alias.Command)
-	}
-
-	fatalIfError(cfg.Validate())
-	bytes, err := cfg.Marshal()
-	fatalIfError(err)
-
-	fatalIfError(ioutil.WriteFile(gitconfigPath, bytes, 0o600))
-}
-
 func getRepos(ctx context.Context, conf *Configuration, client *github.Client) (repositories []Repo) {
 	var repos []*github.Repository
 	var err error
@@ -148,48 +114,122 @@ func getRepos(ctx context.Context, conf *Configuration, client *github.Client) (
 	}
 
 	for _, repo := range repos {
-		cloneURL := *repo.CloneURL
-		dir := *repo.FullName
-		parent := ""
-
-		if re != nil && re.MatchString(dir) {
+		if re != nil && re.MatchString(*repo.FullName) {
 			continue
 		}
 
-		if *repo.Name == gitAliasesRepo {
-			addGitAliases(ctx, conf, client)
+		r, err := newRepo(ctx, conf, client, repo)
+		fatalIfError(err)
+
+		repositories = append(repositories, r)
+	}
+
+	return repositories
+}
+
+// repoDir returns the directory of a repository in the workspace.
+func repoDir(conf *Configuration, fullName string) string {
+	dir := fullName
+
+	if !conf.SubDirs {
+		dir = strings.ReplaceAll(dir, "/", "_")
+		dir = strings.ReplaceAll(dir, conf.Username+"_", "")
+	}
+
+	return conf.BaseDir + "/" + dir
+}
+
+// newRepo returns the configuration of a repository on GitHub.
+func newRepo(ctx context.Context, conf *Configuration, client *github.Client, repo *github.Repository) (Repo, error) {
+	var err error
+
+	cloneURL := *repo.CloneURL
+	dir := repoDir(conf, *repo.FullName)
+	parent := ""
+
+	if *repo.Fork {
+		repo, _, err = client.Repositories.GetByID(ctx, *repo.ID)
+		if err != nil {
+			return Repo{}, err
 		}
 
-		if *repo.Fork {
-			repo, _, err = client.Repositories.GetByID(ctx, *repo.ID)
-			fatalIfError(err)
+		parent = *repo.Parent.CloneURL
+	}
+
+	return Repo{
+		URL:      authURL(conf, cloneURL),
+		Dir:      dir,
+		Branch:   *repo.DefaultBranch,
+		Parent:   parent,
+		AutoTags: repo.Topics,
+	}, nil
+}
+
+// authURL adds the credentials of the user to an HTTP(S) clone URL.
+func authURL(conf *Configuration, cloneURL string) string {
+	if conf.Token != "" {
+		urlPrefix := conf.Username + ":" + conf.Token + "@"
+		cloneURL = strings.ReplaceAll(cloneURL, "https://", "https://"+urlPrefix)
+		cloneURL = strings.ReplaceAll(cloneURL, "http://", "http://"+urlPrefix)
+	}
+
+	return cloneURL
+}
+
+// keepRepoSettings copies the per-repository settings of the existing
+// repositories to the newly discovered ones.
+func keepRepoSettings(existing, repos []Repo) {
+	settings := make(map[string]Repo, len(existing))
+	for _, r := range existing {
+		settings[r.Dir] = r
+	}
 
-			parent = *repo.Parent.CloneURL
+	for i := range repos {
+		r, ok := settings[repos[i].Dir]
+		if !ok {
+			// Adopted clones may be kept in another directory
+			for _, e := range existing {
+				if sameRepoURL(e.URL, repos[i].URL) {
+					r, ok = e, true
+					repos[i].Dir = e.Dir
+
+					break
+				}
+			}
 		}
 
-		if conf.Token != "" {
-			urlPrefix := conf.Username + ":" + conf.Token + "@"
-			cloneURL = strings.ReplaceAll(cloneURL, "https://", "https://"+urlPrefix)
-			cloneURL = strings.ReplaceAll(cloneURL, "http://", "http://"+urlPrefix)
+		if ok {
+			repos[i].Clone = r.Clone
+			repos[i].SkipLFS = r.SkipLFS
+			repos[i].Backend = r.Backend
+			repos[i].SkipIdentity = r.SkipIdentity
+			repos[i].SkipHooks = r.SkipHooks
+			repos[i].Pinned = r.Pinned
+			repos[i].Tags = r.Tags
 		}
+	}
+}
 
-		if !conf.SubDirs {
-			dir = strings.ReplaceAll(dir, "/", "_")
-			dir = strings.ReplaceAll(dir, conf.Username+"_", "")
+// addPinnedRepos appends the pinned repositories which weren't discovered to repos.
+func addPinnedRepos(existing, repos []Repo) []Repo {
+	for _, e := range existing {
+		if e.Pinned && findRepo(repos, e) < 0 {
+			repos = append(repos, e)
 		}
+	}
 
-		dir = conf.BaseDir + "/" + dir
-		branch := *repo.DefaultBranch
+	return repos
+}
 
-		repositories = append(repositories, Repo{
-			URL:    cloneURL,
-			Dir:    dir,
-			Branch: branch,
-			Parent: parent,
-		})
+// findRepo returns the index of the repository with the same directory or URL, or -1.
+func findRepo(repos []Repo, repo Repo) int {
+	for i, r := range repos {
+		if samePath(r.Dir, repo.Dir) || sameRepoURL(r.URL, repo.URL) {
+			return i
+		}
 	}
 
-	return repositories
+	return -1
 }
 
 func runInit(conf *Configuration, update bool) {
@@ -201,6 +241,10 @@ func runInit(conf *Configuration, update bool) {
 		return
 	}
 
+	if lockRequired {
+		fatalIfError(lockWorkspace(waitForLock))
+	}
+
 	// GetUint returns 0 if the flag was not set or if there is any error
 	con, _ := rootCmd.PersistentFlags().GetUint("concurrency")
 	conf.Concurrency = con
@@ -222,13 +266,20 @@ func runInit(conf *Configuration, update bool) {
 		conf.Fullname = usr.GetLogin()
 	}
 
-	if usr.GetEmail() != "" {
-		conf.Email = usr.GetEmail()
-	} else {
-		conf.Email = conf.Username + "@users.noreply.github.com"
+	conf.NoReplyEmail = noReplyEmail(conf, usr)
+
+	// The email is only given explicitly using init --email, update keeps checking the stored one
+	conf.Email, err = chooseEmail(ctx, conf, client, usr, !update && conf.Email != "")
+	fatalIfError(err)
+
+	repos := getRepos(ctx, conf, client)
+
+	if conf.TeamTags {
+		fatalIfError(addTeamTags(ctx, client, repos))
 	}
 
-	conf.Repos = getRepos(ctx, conf, client)
+	keepRepoSettings(conf.Repos, repos)
+	conf.Repos = addPinnedRepos(conf.Repos, repos)
 
 	// Write config
 	conf.save()
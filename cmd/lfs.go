@@ -0,0 +1,260 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	billy "github.com/go-git/go-billy/v5"
+	git "github.com/go-git/go-git/v5"
+	plumbing "github.com/go-git/go-git/v5/plumbing"
+	gitattributes "github.com/go-git/go-git/v5/plumbing/format/gitattributes"
+	index "github.com/go-git/go-git/v5/plumbing/format/index"
+)
+
+const (
+	lfsFilter         = "lfs"
+	lfsPointerMaxSize = 1024
+	lfsOIDPrefix      = "oid sha256:"
+	lfsSizePrefix     = "size "
+	// lfsSkipEnv makes git-lfs leave the LFS pointers in place
+	lfsSkipEnv     = "GIT_LFS_SKIP_SMUDGE=1"
+	attributesFile = ".gitattributes"
+)
+
+// attributesDirs returns the directories holding a .gitattributes file tracked
+// in the index, parents first. Only the root is returned if the index can't be
+// read, e.g. when .git is a file pointing to another directory.
+func attributesDirs(fs billy.Filesystem) ([][]string, error) {
+	dirs := [][]string{nil}
+
+	f, err := fs.Open(fs.Join(git.GitDirName, "index"))
+	if err != nil {
+		return dirs, nil
+	}
+
+	defer f.Close()
+
+	idx := &index.Index{}
+
+	err = index.NewDecoder(bufio.NewReader(f)).Decode(idx)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, e := range idx.Entries {
+		path := strings.Split(e.Name, "/")
+		if len(path) > 1 && path[len(path)-1] == attributesFile {
+			dirs = append(dirs, path[:len(path)-1])
+		}
+	}
+
+	sort.SliceStable(dirs, func(i, j int) bool { return len(dirs[i]) < len(dirs[j]) })
+
+	return dirs, nil
+}
+
+// readAttributes reads the .gitattributes file of a directory. Only the file at
+// the root may define macros.
+func readAttributes(fs billy.Filesystem, dir []string) ([]gitattributes.MatchAttribute, error) {
+	f, err := fs.Open(fs.Join(append(dir[:len(dir):len(dir)], attributesFile)...))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+
+	return gitattributes.ReadAttributes(f, dir, len(dir) == 0)
+}
+
+// lfsMatcher returns a matcher for the gitattributes of the worktree,
+// or nil if no files are tracked by LFS. Unlike gitattributes.ReadPatterns,
+// it doesn't walk the worktree and only reads the tracked .gitattributes files.
+func lfsMatcher(fs billy.Filesystem) (gitattributes.Matcher, error) {
+	dirs, err := attributesDirs(fs)
+	if err != nil {
+		return nil, err
+	}
+
+	var patterns []gitattributes.MatchAttribute
+
+	for _, dir := range dirs {
+		p, err := readAttributes(fs, dir)
+		if err != nil {
+			return nil, err
+		}
+
+		patterns = append(patterns, p...)
+	}
+
+	for _, p := range patterns {
+		for _, a := range p.Attributes {
+			if a.Name() == "filter" && a.Value() == lfsFilter {
+				return gitattributes.NewMatcher(patterns), nil
+			}
+		}
+	}
+
+	return nil, nil
+}
+
+func usesLFS(fs billy.Filesystem) (bool, error) {
+	m, err := lfsMatcher(fs)
+
+	return m != nil, err
+}
+
+func isLFSFile(m gitattributes.Matcher, path string) bool {
+	attrs, _ := m.Match(strings.Split(path, "/"), []string{"filter"})
+	a, ok := attrs["filter"]
+
+	return ok && a.Value() == lfsFilter
+}
+
+// parseLFSPointer returns the object ID and size stored in an LFS pointer.
+// ok is false if the blob is not an LFS pointer.
+func parseLFSPointer(repository *git.Repository, hash plumbing.Hash) (oid string, size int64, ok bool, err error) {
+	blob, err := repository.BlobObject(hash)
+	if err != nil || blob.Size > lfsPointerMaxSize {
+		return "", 0, false, err
+	}
+
+	r, err := blob.Reader()
+	if err != nil {
+		return "", 0, false, err
+	}
+
+	defer r.Close()
+
+	size = -1
+	scanner := bufio.NewScanner(r)
+
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		switch {
+		case strings.HasPrefix(line, lfsOIDPrefix):
+			oid = strings.TrimPrefix(line, lfsOIDPrefix)
+		case strings.HasPrefix(line, lfsSizePrefix):
+			size, err = strconv.ParseInt(strings.TrimPrefix(line, lfsSizePrefix), 10, 64)
+			if err != nil {
+				return "", 0, false, nil
+			}
+		}
+	}
+
+	return oid, size, oid != "" && size >= 0, scanner.Err()
+}
+
+// isSmudged reports whether the worktree file at path contains the object
+// referenced by the LFS pointer stored in the blob with the given hash.
+func isSmudged(repository *git.Repository, workTree *git.Worktree, path string, hash plumbing.Hash) (bool, error) {
+	oid, size, ok, err := parseLFSPointer(repository, hash)
+	if err != nil || !ok {
+		return false, err
+	}
+
+	fi, err := workTree.Filesystem.Lstat(path)
+	if err != nil || fi.Size() != size {
+		return false, err
+	}
+
+	f, err := workTree.Filesystem.Open(path)
+	if err != nil {
+		return false, err
+	}
+
+	defer f.Close()
+
+	h := sha256.New()
+
+	_, err = io.Copy(h, f)
+	if err != nil {
+		return false, err
+	}
+
+	return hex.EncodeToString(h.Sum(nil)) == oid, nil
+}
+
+// worktreeStatus returns the status of the worktree. go-git doesn't run the LFS
+// clean filter, so files whose content matches their LFS pointer are removed
+// from the status instead of being reported as modified.
+func worktreeStatus(repository *git.Repository, workTree *git.Worktree) (git.Status, error) {
+	repoStatus, err := workTree.Status()
+	if err != nil {
+		return nil, err
+	}
+
+	m, err := lfsMatcher(workTree.Filesystem)
+	if err != nil || m == nil {
+		return repoStatus, err
+	}
+
+	idx, err := repository.Storer.Index()
+	if err != nil {
+		return nil, err
+	}
+
+	for path, fs := range repoStatus {
+		if fs.Staging != git.Unmodified || fs.Worktree != git.Modified || !isLFSFile(m, path) {
+			continue
+		}
+
+		entry, err := idx.Entry(path)
+		if err != nil {
+			return nil, err
+		}
+
+		smudged, err := isSmudged(repository, workTree, path, entry.Hash)
+		if err != nil {
+			return nil, err
+		}
+
+		if smudged {
+			delete(repoStatus, path)
+		}
+	}
+
+	return repoStatus, nil
+}
+
+// needsLFS reports whether the LFS objects of a repository have to be downloaded.
+func needsLFS(repo Repo, fs billy.Filesystem) (bool, error) {
+	if repo.SkipLFS {
+		return false, nil
+	}
+
+	return usesLFS(fs)
+}
+
+// installLFS configures the LFS filters in a repository, so that the git
+// executable replaces the LFS pointers when updating the worktree.
+func installLFS(ctx context.Context, repo Repo) error {
+	return execGit(ctx, repo.Dir, "lfs", "install", "--local")
+}
+
+// pullLFS downloads the LFS objects of a repository and replaces the LFS pointers
+// in the worktree. go-git doesn't support LFS, so this is delegated to git-lfs.
+func pullLFS(ctx context.Context, repo Repo, fs billy.Filesystem) error {
+	lfs, err := needsLFS(repo, fs)
+	if err != nil || !lfs {
+		return err
+	}
+
+	err = installLFS(ctx, repo)
+	if err != nil {
+		return err
+	}
+
+	return execGit(ctx, repo.Dir, "lfs", "pull")
+}
@@ -0,0 +1,173 @@
+package cmd
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	memfs "github.com/go-git/go-billy/v5/memfs"
+	util "github.com/go-git/go-billy/v5/util"
+	git "github.com/go-git/go-git/v5"
+	object "github.com/go-git/go-git/v5/plumbing/object"
+)
+
+const lfsAttributes = "*.bin filter=lfs diff=lfs merge=lfs -text\n"
+
+// lfsPointer returns the LFS pointer of a file with the given content.
+func lfsPointer(content string) string {
+	sum := sha256.Sum256([]byte(content))
+
+	return fmt.Sprintf("version https://git-lfs.github.com/spec/v1\noid sha256:%s\nsize %d\n",
+		hex.EncodeToString(sum[:]), len(content))
+}
+
+func TestUsesLFS(t *testing.T) {
+	tests := []struct {
+		attributes string
+		want       bool
+	}{
+		{"", false},
+		{"*.txt text eol=lf\n", false},
+		{"*.txt filter=other\n", false},
+		{lfsAttributes, true},
+		{"*.txt text\n" + lfsAttributes, true},
+	}
+
+	for _, tt := range tests {
+		fs := memfs.New()
+
+		if tt.attributes != "" {
+			err := util.WriteFile(fs, ".gitattributes", []byte(tt.attributes), 0o600)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		got, err := usesLFS(fs)
+		if err != nil || got != tt.want {
+			t.Errorf("usesLFS(%q) = %v, %v, want %v", tt.attributes, got, err, tt.want)
+		}
+
+		lfs, err := needsLFS(Repo{SkipLFS: true}, fs)
+		if err != nil || lfs {
+			t.Errorf("needsLFS with SkipLFS = %v, %v", lfs, err)
+		}
+	}
+
+	fs := memfs.New()
+
+	err := util.WriteFile(fs, ".gitattributes", []byte(lfsAttributes), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := lfsMatcher(fs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !isLFSFile(m, "data/big.bin") || isLFSFile(m, "README") {
+		t.Error("isLFSFile doesn't match the LFS patterns")
+	}
+}
+
+// TestWorktreeStatusLFS commits LFS pointers using go-git, which doesn't run the LFS
+// filters, and replaces them in the worktree like git-lfs does when smudging.
+func TestWorktreeStatusLFS(t *testing.T) {
+	dir := t.TempDir()
+
+	repository, err := git.PlainInit(dir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	workTree, err := repository.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files := map[string]string{
+		".gitattributes": lfsAttributes,
+		"smudged.bin":    lfsPointer("smudged content"),
+		"changed.bin":    lfsPointer("original content"),
+		"pointer.bin":    lfsPointer("not downloaded"),
+		"README":         "readme\n",
+	}
+
+	for name, content := range files {
+		writeFile(t, filepath.Join(dir, name), content)
+
+		_, err = workTree.Add(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	_, err = workTree.Commit("Add files", &git.CommitOptions{
+		Author: &object.Signature{Name: "Test", Email: "test@example.com"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	writeFile(t, filepath.Join(dir, "smudged.bin"), "smudged content")
+	writeFile(t, filepath.Join(dir, "changed.bin"), "changed content")
+	writeFile(t, filepath.Join(dir, "README"), "changed\n")
+
+	repoStatus, err := worktreeStatus(repository, workTree)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, want := range map[string]bool{
+		"smudged.bin": false,
+		"pointer.bin": false,
+		"changed.bin": true,
+		"README":      true,
+	} {
+		s, ok := repoStatus[name]
+		if got := ok && s.Worktree == git.Modified; got != want {
+			t.Errorf("%s reported as modified: %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestLFSMatcherNested(t *testing.T) {
+	dir := t.TempDir()
+
+	repository, err := git.PlainInit(dir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	workTree, err := repository.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, sub := range []string{"tracked", "untracked"} {
+		err = os.Mkdir(filepath.Join(dir, sub), 0o700)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		writeFile(t, filepath.Join(dir, sub, ".gitattributes"), lfsAttributes)
+	}
+
+	_, err = workTree.Add("tracked/.gitattributes")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := lfsMatcher(workTree.Filesystem)
+	if err != nil || m == nil {
+		t.Fatalf("lfsMatcher = %v, %v", m, err)
+	}
+
+	if !isLFSFile(m, "tracked/big.bin") || isLFSFile(m, "untracked/big.bin") || isLFSFile(m, "big.bin") {
+		t.Error("isLFSFile doesn't match the patterns of the tracked .gitattributes files only")
+	}
+}
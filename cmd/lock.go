@@ -0,0 +1,91 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const (
+	lockFile       = ".gr.lock"
+	lockAnnotation = "lock"
+)
+
+var (
+	errWorkspaceLocked = errors.New("another gr run is active in this workspace")
+	errLockBusy        = errors.New("lock is held by another process")
+)
+
+// mutating is the annotation of the commands which change the workspace.
+// They hold the workspace lock, so that they don't run concurrently.
+var mutating = map[string]string{lockAnnotation: "true"}
+
+var (
+	// lockRequired is set if the running command holds the workspace lock.
+	lockRequired bool
+	// waitForLock makes commands wait for other runs instead of failing.
+	waitForLock bool
+	// workspaceLock is the open lock file while the lock is held.
+	workspaceLock *os.File
+)
+
+// lockHolder returns the description of the process holding the lock, as recorded in the lock file.
+func lockHolder(f *os.File) string {
+	b, err := ioutil.ReadAll(f)
+	pid := strings.TrimSpace(string(b))
+
+	if err != nil || pid == "" {
+		return ""
+	}
+
+	return " (PID " + pid + ")"
+}
+
+// lockWorkspace acquires the advisory lock of the workspace in the current directory,
+// which is held until gr exits. If another run holds it, this fails unless wait is set.
+func lockWorkspace(wait bool) error {
+	if workspaceLock != nil {
+		return nil
+	}
+
+	f, err := os.OpenFile(lockFile, os.O_RDWR|os.O_CREATE, 0o600)
+	if err != nil {
+		return err
+	}
+
+	err = tryLockFile(f)
+	if errors.Is(err, errLockBusy) {
+		holder := lockHolder(f)
+
+		if !wait {
+			f.Close()
+
+			return fmt.Errorf("%w%s, use --wait to wait for it to finish", errWorkspaceLocked, holder)
+		}
+
+		fmt.Printf("Waiting for another gr run%s to finish...\n", holder)
+
+		err = waitLockFile(f)
+	}
+
+	if err == nil {
+		err = f.Truncate(0)
+	}
+
+	if err == nil {
+		_, err = f.WriteAt([]byte(strconv.Itoa(os.Getpid())+"\n"), 0)
+	}
+
+	if err != nil {
+		f.Close()
+
+		return err
+	}
+
+	workspaceLock = f
+
+	return nil
+}
@@ -0,0 +1,25 @@
+//go:build !windows
+// +build !windows
+
+package cmd
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// tryLockFile locks a file exclusively, returning errLockBusy if another process holds the lock.
+func tryLockFile(f *os.File) error {
+	err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+	if errors.Is(err, syscall.EWOULDBLOCK) {
+		return errLockBusy
+	}
+
+	return err
+}
+
+// waitLockFile locks a file exclusively, waiting for other processes to release the lock.
+func waitLockFile(f *os.File) error {
+	return syscall.Flock(int(f.Fd()), syscall.LOCK_EX)
+}
@@ -0,0 +1,36 @@
+//go:build windows
+// +build windows
+
+package cmd
+
+import (
+	"errors"
+	"os"
+
+	windows "golang.org/x/sys/windows"
+)
+
+// lockOffset is the offset of the locked byte. Locks on Windows are mandatory, so it
+// is placed beyond the end of the file, leaving the PID readable by other processes.
+const lockOffset = 0x7fffffff
+
+func lockFileEx(f *os.File, flags uint32) error {
+	overlapped := &windows.Overlapped{OffsetHigh: lockOffset}
+
+	return windows.LockFileEx(windows.Handle(f.Fd()), windows.LOCKFILE_EXCLUSIVE_LOCK|flags, 0, 1, 0, overlapped)
+}
+
+// tryLockFile locks a file exclusively, returning errLockBusy if another process holds the lock.
+func tryLockFile(f *os.File) error {
+	err := lockFileEx(f, windows.LOCKFILE_FAIL_IMMEDIATELY)
+	if errors.Is(err, windows.ERROR_LOCK_VIOLATION) {
+		return errLockBusy
+	}
+
+	return err
+}
+
+// waitLockFile locks a file exclusively, waiting for other processes to release the lock.
+func waitLockFile(f *os.File) error {
+	return lockFileEx(f, 0)
+}
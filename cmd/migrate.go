@@ -0,0 +1,115 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// configVersion is the version of the configuration format written by this version of gr.
+// When changing the format in an incompatible way, increase it and add a migration.
+const configVersion = 1
+
+var errConfTooNew = errors.New("the configuration was written by a newer version of gr, " +
+	"please update gr using 'gr version -u'")
+
+// configMigration upgrades a configuration, given as decoded JSON, to the next version.
+type configMigration func(map[string]interface{}) error
+
+// configMigrations holds the migrations of the configuration, the migration from
+// version i to version i+1 being at index i.
+var configMigrations = []configMigration{
+	// Version 0 is the format without a version field, which is added by saving.
+	// Files written before retries were added get the default number of retries.
+	func(raw map[string]interface{}) error {
+		if _, ok := raw["retries"]; !ok {
+			raw["retries"] = defaultRetries
+		}
+
+		return nil
+	},
+}
+
+// configFileVersion returns the version of a configuration file.
+func configFileVersion(data []byte) (int, error) {
+	var v struct {
+		Version int `json:"version"`
+	}
+
+	return v.Version, json.Unmarshal(data, &v)
+}
+
+// needsUpgrade reports whether a configuration file was written by an older version of gr.
+func needsUpgrade(data []byte) bool {
+	version, err := configFileVersion(data)
+
+	return err == nil && version < configVersion
+}
+
+// migrateConfig upgrades a configuration to the current version.
+func migrateConfig(data []byte, version int) ([]byte, error) {
+	var raw map[string]interface{}
+
+	err := json.Unmarshal(data, &raw)
+	if err != nil {
+		return nil, err
+	}
+
+	for v := version; v < configVersion; v++ {
+		err = configMigrations[v](raw)
+		if err != nil {
+			return nil, fmt.Errorf("migrating the configuration to version %d: %w", v+1, err)
+		}
+	}
+
+	raw["version"] = configVersion
+
+	return json.Marshal(raw)
+}
+
+// upgradeConfig migrates the configuration file if it was written by an older version of gr,
+// keeping a backup of the previous file. It returns the content of the current file.
+func upgradeConfig(data []byte) ([]byte, error) {
+	version, err := configFileVersion(data)
+	if err != nil {
+		return nil, err
+	}
+
+	if version > configVersion {
+		return nil, fmt.Errorf("%w (version %d, supported %d)", errConfTooNew, version, configVersion)
+	}
+
+	if version == configVersion {
+		return data, nil
+	}
+
+	migrated, err := migrateConfig(data, version)
+	if err != nil {
+		return nil, err
+	}
+
+	var conf Configuration
+
+	err = json.Unmarshal(migrated, &conf)
+	if err != nil {
+		return nil, err
+	}
+
+	backup := configFile + ".v" + strconv.Itoa(version) + ".bak"
+
+	err = writeFileAtomic(backup, data, 0o600)
+	if err != nil {
+		return nil, err
+	}
+
+	err = conf.write()
+	if err != nil {
+		return nil, err
+	}
+
+	fmt.Printf("Configuration migrated from version %d to %d, the previous file was saved to %s.\n",
+		version, configVersion, backup)
+
+	return migrated, nil
+}
@@ -0,0 +1,254 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strconv"
+	"time"
+
+	color "github.com/fatih/color"
+	git "github.com/go-git/go-git/v5"
+	gitconfig "github.com/go-git/go-git/v5/config"
+	plumbing "github.com/go-git/go-git/v5/plumbing"
+)
+
+const (
+	grConfigSection  = "gr"
+	lastSyncOption   = "lastSync"
+	mirrorOption     = "mirror"
+	lastSyncTimeForm = time.RFC3339
+)
+
+var errMirrorWorkspace = errors.New("not supported in mirror workspaces")
+
+// mirrorRefSpecs returns the refspecs of the references mirrored in the workspace.
+func mirrorRefSpecs(conf *Configuration) []gitconfig.RefSpec {
+	if conf.MirrorPullRequests {
+		return []gitconfig.RefSpec{"+refs/*:refs/*"}
+	}
+
+	return []gitconfig.RefSpec{
+		"+refs/heads/*:refs/heads/*",
+		"+refs/tags/*:refs/tags/*",
+	}
+}
+
+func openMirror(conf *Configuration, repo Repo) (*git.Repository, error) {
+	if pathExists(repo.Dir) {
+		return git.PlainOpen(repo.Dir)
+	}
+
+	repository, err := initMirror(conf, repo)
+	if err != nil {
+		// Don't leave half-initialized mirrors behind, they would never be set up again
+		_ = os.RemoveAll(repo.Dir)
+
+		return nil, err
+	}
+
+	return repository, nil
+}
+
+// initMirror creates a bare repository with the origin remote of a mirror.
+func initMirror(conf *Configuration, repo Repo) (*git.Repository, error) {
+	repository, err := git.PlainInit(repo.Dir, true)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = repository.CreateRemote(&gitconfig.RemoteConfig{
+		Name:  git.DefaultRemoteName,
+		URLs:  []string{repo.URL},
+		Fetch: mirrorRefSpecs(conf),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Mark the remote as mirror, so that the repository can also be used with the git executable
+	repoConf, err := repository.Config()
+	if err != nil {
+		return nil, err
+	}
+
+	repoConf.Raw.Section("remote").Subsection(git.DefaultRemoteName).SetOption(mirrorOption, "true")
+
+	return repository, repository.Storer.SetConfig(repoConf)
+}
+
+// pruneMirror deletes the mirrored references which no longer exist on the remote
+// and points HEAD to the default branch of the remote. It returns the number of
+// deleted references.
+func pruneMirror(ctx context.Context, conf *Configuration, repository *git.Repository) (int, error) {
+	remote, err := repository.Remote(git.DefaultRemoteName)
+	if err != nil {
+		return 0, err
+	}
+
+	remoteRefs, err := remote.ListContext(ctx, &git.ListOptions{})
+	if err != nil {
+		return 0, err
+	}
+
+	exists := make(map[plumbing.ReferenceName]bool, len(remoteRefs))
+	for _, r := range remoteRefs {
+		exists[r.Name()] = true
+
+		if r.Name() == plumbing.HEAD && r.Target() != "" {
+			err = repository.Storer.SetReference(plumbing.NewSymbolicReference(plumbing.HEAD, r.Target()))
+			if err != nil {
+				return 0, err
+			}
+		}
+	}
+
+	localRefs, err := repository.References()
+	if err != nil {
+		return 0, err
+	}
+
+	var stale []plumbing.ReferenceName
+
+	err = localRefs.ForEach(func(r *plumbing.Reference) error {
+		if exists[r.Name()] {
+			return nil
+		}
+
+		for _, spec := range mirrorRefSpecs(conf) {
+			if spec.Match(r.Name()) {
+				stale = append(stale, r.Name())
+
+				break
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	for _, name := range stale {
+		err = repository.Storer.RemoveReference(name)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	return len(stale), nil
+}
+
+func setLastSync(repository *git.Repository, t time.Time) error {
+	repoConf, err := repository.Config()
+	if err != nil {
+		return err
+	}
+
+	repoConf.Raw.Section(grConfigSection).SetOption(lastSyncOption, t.Format(lastSyncTimeForm))
+
+	return repository.Storer.SetConfig(repoConf)
+}
+
+func lastSync(repository *git.Repository) (time.Time, error) {
+	repoConf, err := repository.Config()
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	value := repoConf.Raw.Section(grConfigSection).Option(lastSyncOption)
+	if value == "" {
+		return time.Time{}, nil
+	}
+
+	return time.Parse(lastSyncTimeForm, value)
+}
+
+func runMirrorPull(ctx context.Context, conf *Configuration, repo Repo, status *StatusList) {
+	repository, err := openMirror(conf, repo)
+	// If we get ErrRepositoryNotExists here, it means the repo is broken
+	if errors.Is(err, git.ErrRepositoryNotExists) {
+		status.appendFailure(repo.Dir, "broken")
+
+		return
+	}
+
+	if err != nil {
+		status.appendError(repo.Dir, err)
+
+		return
+	}
+
+	err = retry(ctx, conf.Retries, func() error {
+		return repository.FetchContext(ctx, &git.FetchOptions{
+			RefSpecs: mirrorRefSpecs(conf),
+			Tags:     git.NoTags,
+			Force:    true,
+		})
+	})
+	if err != nil && !errors.Is(err, git.NoErrAlreadyUpToDate) {
+		status.appendError(repo.Dir, err)
+
+		return
+	}
+
+	pruned, err := pruneMirror(ctx, conf, repository)
+	if err != nil {
+		status.appendError(repo.Dir, err)
+
+		return
+	}
+
+	err = setLastSync(repository, time.Now())
+	if err != nil {
+		status.appendError(repo.Dir, err)
+
+		return
+	}
+
+	if pruned > 0 {
+		status.append(repo.Dir, color.GreenString("ok")+"\t"+
+			color.YellowString("pruned "+strconv.Itoa(pruned)+" refs"))
+
+		return
+	}
+
+	status.append(repo.Dir, color.GreenString("ok"))
+}
+
+func runMirrorStatus(repo Repo, status *StatusList) {
+	if !pathExists(repo.Dir) {
+		status.append(repo.Dir, color.RedString("absent"))
+
+		return
+	}
+
+	repository, err := git.PlainOpen(repo.Dir)
+	// If we get ErrRepositoryNotExists here, it means the repo is broken
+	if errors.Is(err, git.ErrRepositoryNotExists) {
+		status.append(repo.Dir, color.RedString("broken"))
+
+		return
+	}
+
+	if err != nil {
+		status.appendError(repo.Dir, err)
+
+		return
+	}
+
+	t, err := lastSync(repository)
+	if err != nil {
+		status.appendError(repo.Dir, err)
+
+		return
+	}
+
+	if t.IsZero() {
+		status.append(repo.Dir, color.RedString("never synced"))
+
+		return
+	}
+
+	status.append(repo.Dir, color.GreenString("synced "+t.Local().Format("2006-01-02 15:04:05")))
+}
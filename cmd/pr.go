@@ -0,0 +1,244 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"text/template"
+
+	color "github.com/fatih/color"
+	plumbing "github.com/go-git/go-git/v5/plumbing"
+	github "github.com/google/go-github/github"
+	cobra "github.com/spf13/cobra"
+)
+
+var errNoDefaultBranch = errors.New("default branch of the repository is unknown")
+
+type prOptions struct {
+	branch string
+	base   string
+	title  *template.Template
+	body   *template.Template
+}
+
+// prTemplateData holds the values available in the title and body templates of pull requests.
+type prTemplateData struct {
+	Owner  string
+	Name   string
+	Dir    string
+	Branch string
+	Base   string
+}
+
+func init() {
+	var opts prOptions
+	var title, body, bodyFile string
+
+	prCmd := &cobra.Command{
+		Use:   "pr",
+		Short: "Manage pull requests",
+		Run: func(cmd *cobra.Command, args []string) {
+			err := cmd.Help()
+			fatalIfError(err)
+		},
+	}
+
+	prCreateCmd := &cobra.Command{
+		Use:   "create",
+		Short: "Open pull requests for the pushed feature branches of all repositories",
+		Run: func(cmd *cobra.Command, args []string) {
+			var err error
+
+			if bodyFile != "" {
+				var content []byte
+
+				content, err = ioutil.ReadFile(bodyFile)
+				fatalIfError(err)
+
+				body = string(content)
+			}
+
+			opts.title, err = template.New("title").Parse(title)
+			fatalIfError(err)
+			opts.body, err = template.New("body").Parse(body)
+			fatalIfError(err)
+
+			repoLoop(func(ctx context.Context, conf *Configuration, repo Repo, status *StatusList) {
+				runPRCreate(ctx, conf, repo, status, opts)
+			}, "Creating pull requests")
+		},
+	}
+
+	prCreateCmd.Flags().StringVarP(&title, "title", "t", "", "Title template of the pull requests")
+	fatalIfError(prCreateCmd.MarkFlagRequired("title"))
+	prCreateCmd.Flags().StringVarP(&body, "body", "m", "", "Body template of the pull requests")
+	prCreateCmd.Flags().StringVarP(&bodyFile, "body-file", "F", "", "Read the body template from a file")
+	prCreateCmd.Flags().StringVarP(&opts.branch, "branch", "B", "",
+		"Feature branch to open pull requests for (default: the checked out branch)")
+	prCreateCmd.Flags().StringVar(&opts.base, "base", "",
+		"Branch to merge into (default: the default branch of the parent or of the repository)")
+	addGroupFlag(prCreateCmd)
+
+	prCmd.AddCommand(prCreateCmd)
+	rootCmd.AddCommand(prCmd)
+}
+
+func executeTemplate(t *template.Template, data prTemplateData) (string, error) {
+	var buf bytes.Buffer
+
+	err := t.Execute(&buf, data)
+
+	return buf.String(), err
+}
+
+// prBase returns the owner, name and branch of the repository the pull request of a repository
+// is opened against, which is the parent for forks and the repository itself otherwise.
+func prBase(ctx context.Context, client *github.Client, repo Repo, opts prOptions) (owner, name, branch string, err error) {
+	if repo.Parent == "" {
+		owner, name, err = repoFullName(repo.URL)
+		branch = repo.Branch
+	} else {
+		owner, name, err = repoFullName(repo.Parent)
+	}
+
+	if err != nil {
+		return "", "", "", err
+	}
+
+	if opts.base != "" {
+		return owner, name, opts.base, nil
+	}
+
+	if branch == "" {
+		parent, _, err := client.Repositories.Get(ctx, owner, name)
+		if err != nil {
+			return "", "", "", err
+		}
+
+		branch = parent.GetDefaultBranch()
+	}
+
+	if branch == "" {
+		return "", "", "", errNoDefaultBranch
+	}
+
+	return owner, name, branch, nil
+}
+
+func runPRCreate(ctx context.Context, conf *Configuration, repo Repo, status *StatusList, opts prOptions) {
+	if conf.Mirror {
+		status.appendError(repo.Dir, errMirrorWorkspace)
+
+		return
+	}
+
+	if !pathExists(repo.Dir) {
+		status.append(repo.Dir, color.RedString("absent"))
+
+		return
+	}
+
+	backend, err := conf.backend(repo)
+	if err != nil {
+		status.appendError(repo.Dir, err)
+
+		return
+	}
+
+	branch := opts.branch
+	if branch == "" {
+		repoStatus, err := backend.Status(ctx, repo)
+		if err != nil {
+			status.appendError(repo.Dir, err)
+
+			return
+		}
+
+		branch = repoStatus.Branch
+	}
+
+	if branch == repo.Branch || branch == plumbing.HEAD.String() {
+		status.append(repo.Dir, color.YellowString("no feature branch"))
+
+		return
+	}
+
+	remoteHash, err := backend.RemoteBranch(ctx, repo, branch)
+	if err != nil {
+		status.appendError(repo.Dir, err)
+
+		return
+	}
+
+	if remoteHash.IsZero() {
+		status.append(repo.Dir, color.YellowString(branch+" not pushed"))
+
+		return
+	}
+
+	headOwner, name, err := repoFullName(repo.URL)
+	if err != nil {
+		status.appendError(repo.Dir, err)
+
+		return
+	}
+
+	client := newGithubClient(conf)
+
+	baseOwner, baseName, base, err := prBase(ctx, client, repo, opts)
+	if err != nil {
+		status.appendError(repo.Dir, err)
+
+		return
+	}
+
+	head := headOwner + ":" + branch
+
+	existing, _, err := client.PullRequests.List(ctx, baseOwner, baseName, &github.PullRequestListOptions{
+		State: "open",
+		Head:  head,
+		Base:  base,
+	})
+	if err != nil {
+		status.appendError(repo.Dir, err)
+
+		return
+	}
+
+	if len(existing) > 0 {
+		status.append(repo.Dir, color.YellowString("exists")+"\t"+existing[0].GetHTMLURL())
+
+		return
+	}
+
+	data := prTemplateData{Owner: headOwner, Name: name, Dir: repo.Dir, Branch: branch, Base: base}
+
+	title, err := executeTemplate(opts.title, data)
+	if err != nil {
+		status.appendError(repo.Dir, err)
+
+		return
+	}
+
+	body, err := executeTemplate(opts.body, data)
+	if err != nil {
+		status.appendError(repo.Dir, err)
+
+		return
+	}
+
+	pr, _, err := client.PullRequests.Create(ctx, baseOwner, baseName, &github.NewPullRequest{
+		Title: &title,
+		Head:  &head,
+		Base:  &base,
+		Body:  &body,
+	})
+	if err != nil {
+		status.appendError(repo.Dir, err)
+
+		return
+	}
+
+	status.append(repo.Dir, color.GreenString("created")+"\t"+pr.GetHTMLURL())
+}
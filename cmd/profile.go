@@ -0,0 +1,313 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	formatconfig "github.com/go-git/go-git/v5/plumbing/format/config"
+	cobra "github.com/spf13/cobra"
+)
+
+const (
+	profileFileName     = "profile.json"
+	profileVersion      = 1
+	managedConfigOption = "managedConfig"
+)
+
+var (
+	errInvalidConfigKey  = errors.New("invalid git configuration key")
+	errUnsupportedFormat = errors.New("unsupported profile version, please update gr")
+)
+
+// ConfigSource holds the repository and file from which shared git settings are synced.
+type ConfigSource struct {
+	Repo string `json:"repo,omitempty"`
+	File string `json:"file,omitempty"`
+}
+
+// Profile holds the source of the shared git configuration profile, along with the
+// options of the profile which are applied to each repository.
+type Profile struct {
+	ConfigSource
+	Version    int               `json:"version,omitempty"`
+	RepoConfig map[string]string `json:"repoConfig,omitempty"`
+}
+
+// profileFile holds the content of a git configuration profile.
+type profileFile struct {
+	Version int               `json:"version"`
+	Global  map[string]string `json:"global"`
+	Repo    map[string]string `json:"repo"`
+}
+
+// configOption is a git configuration option, e.g. pull.rebase or url.<base>.insteadOf.
+type configOption struct {
+	Key   string
+	Value string
+}
+
+func init() {
+	var source ConfigSource
+	var dryRun bool
+
+	profileCmd := &cobra.Command{
+		Use:   "profile",
+		Short: "Manage the shared git configuration profile",
+		Run: func(cmd *cobra.Command, args []string) {
+			err := cmd.Help()
+			fatalIfError(err)
+		},
+	}
+
+	profileSyncCmd := &cobra.Command{
+		Use:         "sync",
+		Short:       "Apply the shared git configuration profile stored on GitHub",
+		Annotations: mutating,
+		Run: func(cmd *cobra.Command, args []string) {
+			conf := loadConfig()
+
+			if source.Repo != "" {
+				conf.Profile.Repo = source.Repo
+			}
+
+			if source.File != "" {
+				conf.Profile.File = source.File
+			}
+
+			runProfileSync(conf, dryRun)
+		},
+	}
+
+	profileSyncCmd.Flags().StringVar(&source.Repo, "repo", "",
+		"Repository containing the profile, as OWNER/NAME or NAME (default: "+gitAliasesRepo+")")
+	profileSyncCmd.Flags().StringVar(&source.File, "file", "", "Path of the profile in the repository (default: "+profileFileName+")")
+	profileSyncCmd.Flags().BoolVarP(&dryRun, "dry-run", "n", false, "Only show the changes")
+
+	profileCmd.AddCommand(profileSyncCmd)
+	rootCmd.AddCommand(profileCmd)
+}
+
+// configOptions returns the options of a map sorted by key.
+func configOptions(m map[string]string) []configOption {
+	options := make([]configOption, 0, len(m))
+	for k, v := range m {
+		options = append(options, configOption{Key: k, Value: v})
+	}
+
+	sort.Slice(options, func(i, j int) bool {
+		return options[i].Key < options[j].Key
+	})
+
+	return options
+}
+
+// splitConfigKey splits a key into its section, subsection and option name.
+// Like in git, the subsection is everything between the first and the last dot.
+func splitConfigKey(key string) (section, subsection, name string, err error) {
+	first, last := strings.Index(key, "."), strings.LastIndex(key, ".")
+	if first <= 0 || last == len(key)-1 {
+		return "", "", "", fmt.Errorf("%s: %w", key, errInvalidConfigKey)
+	}
+
+	if first < last {
+		subsection = key[first+1 : last]
+	}
+
+	return key[:first], subsection, key[last+1:], nil
+}
+
+// getConfigOption returns the value of an option and whether it is set.
+func getConfigOption(cfg *formatconfig.Config, key string) (string, bool, error) {
+	section, subsection, name, err := splitConfigKey(key)
+	if err != nil || !cfg.HasSection(section) {
+		return "", false, err
+	}
+
+	s := cfg.Section(section)
+	if subsection == "" {
+		return s.Option(name), s.HasOption(name), nil
+	}
+
+	if !s.HasSubsection(subsection) {
+		return "", false, nil
+	}
+
+	ss := s.Subsection(subsection)
+
+	return ss.Option(name), ss.HasOption(name), nil
+}
+
+func setConfigOption(cfg *formatconfig.Config, key, value string) error {
+	section, subsection, name, err := splitConfigKey(key)
+	if err != nil {
+		return err
+	}
+
+	if subsection == "" {
+		cfg.Section(section).SetOption(name, value)
+	} else {
+		cfg.Section(section).Subsection(subsection).SetOption(name, value)
+	}
+
+	return nil
+}
+
+// removeConfigOption removes an option, along with its subsection and section if they become empty.
+func removeConfigOption(cfg *formatconfig.Config, key string) error {
+	section, subsection, name, err := splitConfigKey(key)
+	if err != nil || !cfg.HasSection(section) {
+		return err
+	}
+
+	s := cfg.Section(section)
+
+	if subsection == "" {
+		s.RemoveOption(name)
+	} else if s.HasSubsection(subsection) {
+		ss := s.Subsection(subsection).RemoveOption(name)
+		if len(ss.Options) == 0 {
+			s.RemoveSubsection(subsection)
+		}
+	}
+
+	if len(s.Options) == 0 && len(s.Subsections) == 0 {
+		cfg.RemoveSection(section)
+	}
+
+	return nil
+}
+
+// applyConfigOptions sets the given options in cfg. The options previously set by gr,
+// which are recorded in the record option of the gr section, are removed if they are
+// no longer given. It returns the description of the changes.
+func applyConfigOptions(cfg *formatconfig.Config, options []configOption, record string) ([]string, error) {
+	var changes []string
+
+	wanted := make(map[string]bool, len(options))
+
+	for _, o := range options {
+		wanted[strings.ToLower(o.Key)] = true
+
+		old, exists, err := getConfigOption(cfg, o.Key)
+		if err != nil {
+			return nil, err
+		}
+
+		switch {
+		case !exists:
+			changes = append(changes, fmt.Sprintf("+ %s = %s", o.Key, o.Value))
+		case old != o.Value:
+			changes = append(changes, fmt.Sprintf("~ %s = %s (was %s)", o.Key, o.Value, old))
+		}
+
+		err = setConfigOption(cfg, o.Key, o.Value)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	grSection := cfg.Section(grConfigSection)
+
+	for _, key := range grSection.OptionAll(record) {
+		if wanted[strings.ToLower(key)] {
+			continue
+		}
+
+		old, exists, err := getConfigOption(cfg, key)
+		if err != nil {
+			return nil, err
+		}
+
+		if exists {
+			changes = append(changes, fmt.Sprintf("- %s = %s", key, old))
+		}
+
+		err = removeConfigOption(cfg, key)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	grSection.RemoveOption(record)
+
+	for _, o := range options {
+		grSection.AddOption(record, o.Key)
+	}
+
+	if len(grSection.Options) == 0 && len(grSection.Subsections) == 0 {
+		cfg.RemoveSection(grConfigSection)
+	}
+
+	sort.Strings(changes)
+
+	return changes, nil
+}
+
+// fetchProfile returns the profile stored in the configured repository.
+func fetchProfile(conf *Configuration) (*profileFile, error) {
+	var profile profileFile
+
+	content, err := fetchSourceFile(conf, conf.Profile.ConfigSource, profileFileName)
+	if err != nil {
+		return nil, err
+	}
+
+	err = json.Unmarshal(content, &profile)
+	if err != nil {
+		return nil, err
+	}
+
+	if profile.Version > profileVersion {
+		return nil, fmt.Errorf("%d: %w", profile.Version, errUnsupportedFormat)
+	}
+
+	return &profile, nil
+}
+
+func runProfileSync(conf *Configuration, dryRun bool) {
+	profile, err := fetchProfile(conf)
+	fatalIfError(err)
+	fatalIfError(conf.Hooks.checkHooksPath(profile.Repo))
+
+	path, err := globalGitConfigPath()
+	fatalIfError(err)
+
+	changes, err := updateGitConfig(path, dryRun, func(cfg *formatconfig.Config) ([]string, error) {
+		return applyConfigOptions(cfg, configOptions(profile.Global), managedConfigOption)
+	})
+	fatalIfError(err)
+
+	fmt.Println("Global configuration (" + path + "):")
+
+	for _, c := range changes {
+		fmt.Println(c)
+	}
+
+	// Show the changes of the repositories on an empty configuration holding the previous options
+	repoCfg := formatconfig.New()
+
+	for _, o := range configOptions(conf.Profile.RepoConfig) {
+		fatalIfError(setConfigOption(repoCfg, o.Key, o.Value))
+		repoCfg.Section(grConfigSection).AddOption(managedConfigOption, o.Key)
+	}
+
+	repoChanges, err := applyConfigOptions(repoCfg, configOptions(profile.Repo), managedConfigOption)
+	fatalIfError(err)
+
+	fmt.Println("Repository configuration:")
+
+	for _, c := range repoChanges {
+		fmt.Println(c)
+	}
+
+	if dryRun {
+		return
+	}
+
+	conf.Profile.Version = profile.Version
+	conf.Profile.RepoConfig = profile.Repo
+	conf.save()
+}
@@ -1,43 +1,115 @@
 package cmd
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"os"
+	"sort"
+	"strings"
 
 	color "github.com/fatih/color"
 	git "github.com/go-git/go-git/v5"
 	gitconfig "github.com/go-git/go-git/v5/config"
+	plumbing "github.com/go-git/go-git/v5/plumbing"
 	cobra "github.com/spf13/cobra"
 )
 
+const upstreamRemoteName = "upstream"
+
+var errShallowHistory = errors.New("the history is shallow, the commits can't be compared")
+
 func init() {
+	var retryFailed bool
+
 	pullCmd := &cobra.Command{
-		Use:   "pull",
-		Short: "Pull all repositories",
+		Use:         "pull",
+		Short:       "Pull all repositories",
+		Annotations: mutating,
 		Run: func(cmd *cobra.Command, args []string) {
-			repoLoop(runPull, "Pulling")
+			conf := loadConfig()
+			repos := conf.Repos
+
+			if retryFailed {
+				repos = failedRepos(conf)
+				if len(repos) == 0 {
+					fmt.Println("No repositories failed in the previous run.")
+
+					return
+				}
+			}
+
+			saveFailedRepos(loopRepos(conf, repos, runPull, "Pulling"))
 		},
 	}
 
+	pullCmd.Flags().BoolVar(&retryFailed, "retry-failed", false, "Only pull the repositories which failed in the previous run")
+	addGroupFlag(pullCmd)
+
 	rootCmd.AddCommand(pullCmd)
 }
 
-func updateRepoConfig(conf *Configuration, repository *git.Repository) {
+func updateRepoConfig(conf *Configuration, repo Repo, repository *git.Repository) error {
 	repoConf, err := repository.Config()
-	fatalIfError(err)
+	if err != nil {
+		return err
+	}
+
+	if !repo.SkipIdentity {
+		identity, err := conf.identityOptions(repo)
+		if err != nil {
+			return err
+		}
 
-	section := repoConf.Raw.Section("user")
-	section.SetOption("name", conf.Fullname)
-	section.SetOption("email", conf.Email)
+		_, err = applyConfigOptions(repoConf.Raw, identity, managedIdentityOption)
+		if err != nil {
+			return err
+		}
+
+		// go-git writes the parsed user over the raw configuration
+		repoConf.User.Name = repoConf.Raw.Section("user").Option("name")
+		repoConf.User.Email = repoConf.Raw.Section("user").Option("email")
+	}
+
+	err = conf.Hooks.checkHooksPath(conf.Profile.RepoConfig)
+	if err != nil {
+		return err
+	}
+
+	_, err = applyConfigOptions(repoConf.Raw, configOptions(conf.Profile.RepoConfig), managedConfigOption)
+	if err != nil {
+		return err
+	}
+
+	err = updateHooks(conf, repo, repoConf.Raw)
+	if err != nil {
+		return err
+	}
 
 	err = repoConf.Validate()
-	fatalIfError(err)
+	if err != nil {
+		return err
+	}
 
-	err = repository.Storer.SetConfig(repoConf)
-	fatalIfError(err)
+	return repository.Storer.SetConfig(repoConf)
 }
 
-func pullSubmodule(submodule *git.Submodule) error {
+// addUpstreamRemote adds the upstream remote to forked repositories if it doesn't exist.
+func addUpstreamRemote(repository *git.Repository, repo Repo) error {
+	_, err := repository.Remote(upstreamRemoteName)
+	if repo.Parent == "" || !errors.Is(err, git.ErrRemoteNotFound) {
+		return nil
+	}
+
+	_, err = repository.CreateRemote(&gitconfig.RemoteConfig{
+		Name: upstreamRemoteName,
+		URLs: []string{repo.Parent},
+	})
+
+	return err
+}
+
+func pullSubmodule(ctx context.Context, submodule *git.Submodule) error {
 	status, err := submodule.Status()
 	if err != nil {
 		return fmt.Errorf("submodule: %w", err)
@@ -59,7 +131,7 @@ func pullSubmodule(submodule *git.Submodule) error {
 			return fmt.Errorf("submodule %s: %w", status.Path, err)
 		}
 
-		remoteRefs, err := remote.List(&git.ListOptions{})
+		remoteRefs, err := remote.ListContext(ctx, &git.ListOptions{})
 		if err != nil {
 			return fmt.Errorf("submodule %s: %w", status.Path, err)
 		}
@@ -67,7 +139,7 @@ func pullSubmodule(submodule *git.Submodule) error {
 		for _, v := range remoteRefs {
 			if v.Name() == "HEAD" && v.Target() != "" {
 				branchRef := v.Target()
-				err := repository.Fetch(&git.FetchOptions{
+				err := repository.FetchContext(ctx, &git.FetchOptions{
 					RefSpecs: []gitconfig.RefSpec{"refs/*:refs/*"},
 				})
 				if err != nil && !errors.Is(err, git.NoErrAlreadyUpToDate) {
@@ -93,7 +165,7 @@ func pullSubmodule(submodule *git.Submodule) error {
 		}
 	}
 
-	err = worktree.Pull(&git.PullOptions{})
+	err = worktree.PullContext(ctx, &git.PullOptions{})
 
 	if err != nil && !errors.Is(err, git.NoErrAlreadyUpToDate) {
 		// Ignore NoErrAlreadyUpToDate
@@ -103,70 +175,192 @@ func pullSubmodule(submodule *git.Submodule) error {
 	return nil
 }
 
-func runPull(conf *Configuration, repo Repo, status *StatusList) {
-	var repository *git.Repository
-	var workTree *git.Worktree
-	var err error
+// compareCommits reports whether the local commit can be fast-forwarded to the remote
+// commit and whether the two commits diverged, i.e. neither is an ancestor of the other.
+// It returns errShallowHistory if the history needed to compare them is missing.
+func compareCommits(repository *git.Repository, local, remote plumbing.Hash) (ff, diverged bool, err error) {
+	if local == remote {
+		return false, false, nil
+	}
 
-	if pathExists(repo.Dir) {
-		repository, err = git.PlainOpen(repo.Dir)
-		// If we get ErrRepositoryNotExists here, it means the repo is broken
-		if errors.Is(err, git.ErrRepositoryNotExists) {
-			status.append(repo.Dir, color.RedString("broken"))
+	localCommit, err := repository.CommitObject(local)
+	if err != nil {
+		return false, false, err
+	}
 
-			return
+	remoteCommit, err := repository.CommitObject(remote)
+	if err != nil {
+		return false, false, err
+	}
+
+	ff, err = localCommit.IsAncestor(remoteCommit)
+	if err == nil && !ff {
+		var ahead bool
+
+		ahead, err = remoteCommit.IsAncestor(localCommit)
+		diverged = !ahead
+	}
+
+	if errors.Is(err, plumbing.ErrObjectNotFound) {
+		// The history of shallow and partial clones is incomplete, so the commits can't be compared
+		return false, false, errShallowHistory
+	}
+
+	return ff, diverged, err
+}
+
+// fastForwardBranches fast-forwards all local branches, except the checked out one,
+// whose upstream branch moved ahead. It returns the branches that could not be
+// fast-forwarded because they diverged from their upstream or the history is shallow.
+func fastForwardBranches(repository *git.Repository) (PullResult, error) {
+	var result PullResult
+
+	repoConf, err := repository.Config()
+	if err != nil {
+		return PullResult{}, err
+	}
+
+	head, err := repository.Head()
+	if err != nil {
+		return PullResult{}, err
+	}
+
+	for name, branch := range repoConf.Branches {
+		if branch.Remote == "" || branch.Remote == "." || branch.Merge == "" {
+			continue
+		}
+
+		localName := plumbing.NewBranchReferenceName(name)
+		if localName == head.Name() {
+			// The checked out branch is updated by the worktree pull
+			continue
+		}
+
+		local, err := repository.Reference(localName, true)
+		if errors.Is(err, plumbing.ErrReferenceNotFound) {
+			continue
 		}
 
 		if err != nil {
-			status.appendError(repo.Dir, err)
+			return PullResult{}, err
+		}
 
-			return
+		remoteName := plumbing.NewRemoteReferenceName(branch.Remote, branch.Merge.Short())
+		remote, err := repository.Reference(remoteName, true)
+		if errors.Is(err, plumbing.ErrReferenceNotFound) {
+			continue
 		}
 
-		workTree, err = repository.Worktree()
 		if err != nil {
-			status.appendError(repo.Dir, err)
+			return PullResult{}, err
+		}
+
+		ff, div, err := compareCommits(repository, local.Hash(), remote.Hash())
+		if errors.Is(err, errShallowHistory) {
+			result.Shallow = append(result.Shallow, name)
+
+			continue
+		}
+
+		if err != nil {
+			return PullResult{}, err
+		}
+
+		if div {
+			result.Diverged = append(result.Diverged, name)
+		}
+
+		// Local branches which are only ahead of their upstream are left alone
+		if !ff {
+			continue
+		}
+
+		err = repository.Storer.SetReference(plumbing.NewHashReference(localName, remote.Hash()))
+		if err != nil {
+			return PullResult{}, err
+		}
+	}
+
+	sort.Strings(result.Diverged)
+	sort.Strings(result.Shallow)
+
+	return result, nil
+}
+
+func runPull(ctx context.Context, conf *Configuration, repo Repo, status *StatusList) {
+	var result PullResult
+	var warning string
+
+	if conf.Mirror {
+		runMirrorPull(ctx, conf, repo, status)
+
+		return
+	}
+
+	backend, err := conf.backend(repo)
+	if err != nil {
+		status.appendError(repo.Dir, err)
+
+		return
+	}
+
+	opts := conf.cloneOptions(repo)
+
+	if pathExists(repo.Dir) {
+		repository, err := git.PlainOpen(repo.Dir)
+		// If we get ErrRepositoryNotExists here, it means the repo is broken
+		if errors.Is(err, git.ErrRepositoryNotExists) {
+			status.appendFailure(repo.Dir, "broken")
 
 			return
 		}
 
-		repoStatus, err := workTree.Status()
 		if err != nil {
 			status.appendError(repo.Dir, err)
 
 			return
 		}
 
-		if !repoStatus.IsClean() {
-			status.appendError(repo.Dir, git.ErrWorktreeNotClean)
+		// The directory may hold a clone of another repository, e.g. one made by hand before init.
+		// The origin may also be a valid alias of the repository (SSH host alias, insteadOf
+		// rewrite, old name of a renamed repository), so this is only reported.
+		url, err := originURL(repository)
+		if err != nil {
+			status.appendError(repo.Dir, err)
 
 			return
 		}
 
-		err = workTree.Pull(&git.PullOptions{
-			RecurseSubmodules: git.DefaultSubmoduleRecursionDepth,
+		if !sameRepoURL(url, repo.URL) {
+			warning = "origin is " + redactURL(url)
+		}
+
+		err = retry(ctx, conf.Retries, func() error {
+			result, err = backend.Pull(ctx, repo, opts)
+
+			return err
 		})
 
 		if errors.Is(err, git.ErrNonFastForwardUpdate) {
-			status.append(repo.Dir, color.RedString("non-fast-forward update"))
+			status.appendFailure(repo.Dir, "non-fast-forward update")
 
 			return
 		}
 
-		if errors.Is(err, git.NoErrAlreadyUpToDate) {
-			// Ignore NoErrAlreadyUpToDate
-			err = nil
-		}
-
 		if err != nil {
 			status.appendError(repo.Dir, err)
 
 			return
 		}
 	} else {
-		repository, err = git.PlainClone(repo.Dir, false, &git.CloneOptions{
-			URL:               repo.URL,
-			RecurseSubmodules: git.DefaultSubmoduleRecursionDepth,
+		err = retry(ctx, conf.Retries, func() error {
+			err := backend.Clone(ctx, repo, opts)
+			if err != nil {
+				// Don't leave half-written clones behind, e.g. after a timeout
+				_ = os.RemoveAll(repo.Dir)
+			}
+
+			return err
 		})
 		if err != nil {
 			status.appendError(repo.Dir, err)
@@ -174,7 +368,10 @@ func runPull(conf *Configuration, repo Repo, status *StatusList) {
 			return
 		}
 
-		workTree, err = repository.Worktree()
+		// The clone is kept if updating it fails, the update is done again by the next pull
+		err = retry(ctx, conf.Retries, func() error {
+			return backend.UpdateClone(ctx, repo, opts)
+		})
 		if err != nil {
 			status.appendError(repo.Dir, err)
 
@@ -182,45 +379,40 @@ func runPull(conf *Configuration, repo Repo, status *StatusList) {
 		}
 	}
 
-	submodules, err := workTree.Submodules()
+	repository, err := git.PlainOpen(repo.Dir)
 	if err != nil {
 		status.appendError(repo.Dir, err)
 
 		return
 	}
 
-	for _, s := range submodules {
-		err := pullSubmodule(s)
-		if err != nil {
-			status.appendError(repo.Dir, err)
+	err = updateRepoConfig(conf, repo, repository)
+	if err != nil {
+		status.appendError(repo.Dir, err)
 
-			return
-		}
+		return
 	}
 
-	err = repository.Fetch(&git.FetchOptions{
-		RefSpecs: []gitconfig.RefSpec{"refs/*:refs/*"},
-	})
-	if err != nil && !errors.Is(err, git.NoErrAlreadyUpToDate) {
+	err = addUpstreamRemote(repository, repo)
+	if err != nil {
 		status.appendError(repo.Dir, err)
 
 		return
 	}
 
-	updateRepoConfig(conf, repository)
-	_, err = repository.Remote("upstream")
+	state := color.GreenString("ok")
 
-	if repo.Parent != "" && errors.Is(err, git.ErrRemoteNotFound) {
-		_, err := repository.CreateRemote(&gitconfig.RemoteConfig{
-			Name: "upstream",
-			URLs: []string{repo.Parent},
-		})
-		if err != nil {
-			status.appendError(repo.Dir, err)
+	if len(result.Diverged) > 0 {
+		state += "\t" + color.RedString("diverged: "+strings.Join(result.Diverged, ", "))
+	}
 
-			return
-		}
+	if len(result.Shallow) > 0 {
+		state += "\t" + color.YellowString("shallow, not compared: "+strings.Join(result.Shallow, ", "))
+	}
+
+	if warning != "" {
+		state += "\t" + color.YellowString(warning)
 	}
 
-	status.append(repo.Dir, color.GreenString("ok"))
+	status.append(repo.Dir, state)
 }
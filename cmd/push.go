@@ -1,27 +1,359 @@
 package cmd
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"sort"
+	"strings"
 
 	color "github.com/fatih/color"
 	git "github.com/go-git/go-git/v5"
+	gitconfig "github.com/go-git/go-git/v5/config"
+	plumbing "github.com/go-git/go-git/v5/plumbing"
+	object "github.com/go-git/go-git/v5/plumbing/object"
+	storer "github.com/go-git/go-git/v5/plumbing/storer"
 	transport "github.com/go-git/go-git/v5/plumbing/transport"
 	cobra "github.com/spf13/cobra"
 )
 
+var errStaleLease = errors.New("remote branch changed since the last fetch")
+
+// PushOptions holds the options used when pushing repositories.
+type PushOptions struct {
+	Tags           bool
+	NewBranches    bool
+	ForceWithLease bool
+	DryRun         bool
+}
+
+// RefUpdate describes a remote reference moved by a push.
+type RefUpdate struct {
+	Name    plumbing.ReferenceName
+	Old     plumbing.Hash
+	New     plumbing.Hash
+	Forced  bool
+	Commits int
+}
+
 func init() {
+	var opts PushOptions
+
 	pushCmd := &cobra.Command{
-		Use:   "push",
-		Short: "Push all repositories",
+		Use:         "push",
+		Short:       "Push all repositories",
+		Annotations: mutating,
 		Run: func(cmd *cobra.Command, args []string) {
-			repoLoop(runPush, "Pushing")
+			repoLoop(func(ctx context.Context, conf *Configuration, repo Repo, status *StatusList) {
+				runPush(ctx, conf, repo, status, opts)
+			}, "Pushing")
 		},
 	}
 
+	pushCmd.Flags().BoolVar(&opts.Tags, "tags", false, "Push the tags which don't exist on the remote")
+	pushCmd.Flags().BoolVarP(&opts.NewBranches, "set-upstream", "u", false, "Push new branches and set their upstream")
+	pushCmd.Flags().BoolVar(&opts.ForceWithLease, "force-with-lease", false,
+		"Force-push diverged branches, unless the remote branch changed since the last fetch")
+	pushCmd.Flags().BoolVarP(&opts.DryRun, "dry-run", "n", false, "Only show which refs would be pushed")
+	addGroupFlag(pushCmd)
+
 	rootCmd.AddCommand(pushCmd)
 }
 
-func runPush(conf *Configuration, repo Repo, status *StatusList) {
+func (u RefUpdate) refSpec() gitconfig.RefSpec {
+	spec := gitconfig.RefSpec(u.Name + ":" + u.Name)
+	if u.Forced {
+		spec = "+" + spec
+	}
+
+	return spec
+}
+
+func (u RefUpdate) String() string {
+	commits := fmt.Sprintf("%d commits", u.Commits)
+	if u.Commits == 1 {
+		commits = "1 commit"
+	}
+
+	switch {
+	case u.Old.IsZero() && u.Name.IsTag():
+		return u.Name.Short() + " (new tag)"
+	case u.Old.IsZero():
+		return u.Name.Short() + " (new, " + commits + ")"
+	case u.Forced:
+		return fmt.Sprintf("%s %.7s...%.7s (forced, %s)", u.Name.Short(), u.Old, u.New, commits)
+	}
+
+	return fmt.Sprintf("%s %.7s..%.7s (%s)", u.Name.Short(), u.Old, u.New, commits)
+}
+
+// ancestors returns the commits reachable from the given commits.
+// Commits which don't exist locally are skipped.
+func ancestors(repository *git.Repository, hashes []plumbing.Hash) (map[plumbing.Hash]bool, error) {
+	seen := make(map[plumbing.Hash]bool)
+
+	for _, h := range hashes {
+		commit, err := repository.CommitObject(h)
+		if errors.Is(err, plumbing.ErrObjectNotFound) || errors.Is(err, plumbing.ErrInvalidType) {
+			continue
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		err = object.NewCommitPreorderIter(commit, seen, nil).ForEach(func(c *object.Commit) error {
+			seen[c.Hash] = true
+
+			return nil
+		})
+		// The history of shallow repositories is incomplete
+		if err != nil && !errors.Is(err, plumbing.ErrObjectNotFound) {
+			return nil, err
+		}
+	}
+
+	return seen, nil
+}
+
+// countCommits returns the number of commits reachable from head which aren't in exclude.
+func countCommits(repository *git.Repository, head plumbing.Hash, exclude map[plumbing.Hash]bool) (int, error) {
+	var count int
+
+	commit, err := repository.CommitObject(head)
+	if err != nil {
+		return 0, err
+	}
+
+	err = object.NewCommitPreorderIter(commit, exclude, nil).ForEach(func(*object.Commit) error {
+		count++
+
+		return nil
+	})
+	if err != nil && !errors.Is(err, plumbing.ErrObjectNotFound) {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+// mayPush reports whether pushing a repository may update any remote reference, judging
+// by the remote branches as they were when last fetched, so that the remote doesn't have
+// to be contacted for repositories with nothing to push. New tags can't be detected this way.
+func mayPush(repository *git.Repository, opts PushOptions) (bool, error) {
+	var ahead bool
+
+	if opts.Tags {
+		return true, nil
+	}
+
+	refs, err := repository.Branches()
+	if err != nil {
+		return false, err
+	}
+
+	err = refs.ForEach(func(r *plumbing.Reference) error {
+		tracking, err := repository.Reference(plumbing.NewRemoteReferenceName(git.DefaultRemoteName,
+			r.Name().Short()), true)
+		if errors.Is(err, plumbing.ErrReferenceNotFound) {
+			// New branches are only pushed when requested
+			if !opts.NewBranches {
+				return nil
+			}
+
+			ahead = true
+
+			return storer.ErrStop
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if tracking.Hash() == r.Hash() {
+			return nil
+		}
+
+		behind, _, err := compareCommits(repository, r.Hash(), tracking.Hash())
+		if err != nil || !behind {
+			ahead = true
+
+			return storer.ErrStop
+		}
+
+		return nil
+	})
+
+	return ahead, err
+}
+
+// planBranchUpdate returns the update of the remote branch pushed from a local branch,
+// or nil if the remote branch already contains the local branch.
+func planBranchUpdate(repository *git.Repository, local *plumbing.Reference,
+	remote plumbing.Hash, opts PushOptions) (*RefUpdate, error) {
+	update := &RefUpdate{Name: local.Name(), Old: remote, New: local.Hash()}
+
+	ff, diverged, err := compareCommits(repository, remote, local.Hash())
+	// The remote branch has commits which weren't fetched, or the history is shallow
+	if errors.Is(err, plumbing.ErrObjectNotFound) || errors.Is(err, errShallowHistory) {
+		err = nil
+		diverged = true
+	}
+
+	switch {
+	case err != nil:
+		return nil, err
+	case ff:
+		return update, nil
+	case !diverged:
+		return nil, nil
+	case !opts.ForceWithLease:
+		return nil, git.ErrNonFastForwardUpdate
+	}
+
+	// Only overwrite the remote branch if it's where it was when it was last fetched
+	tracking, err := repository.Reference(plumbing.NewRemoteReferenceName(git.DefaultRemoteName,
+		local.Name().Short()), true)
+	if errors.Is(err, plumbing.ErrReferenceNotFound) || (err == nil && tracking.Hash() != remote) {
+		return nil, fmt.Errorf("%s: %w", local.Name().Short(), errStaleLease)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	update.Forced = true
+
+	return update, nil
+}
+
+// pushedRemoteHashes returns the remote commits the commits of the branch updates are counted against:
+// the remote branches being updated, and all remote branches if new branches are pushed.
+// Tags and other refs like refs/pull/* aren't included, walking them would be slow on large repositories.
+func pushedRemoteHashes(remoteRefs map[plumbing.ReferenceName]plumbing.Hash, updates []RefUpdate) []plumbing.Hash {
+	var hashes []plumbing.Hash
+	var newBranches bool
+
+	for _, u := range updates {
+		switch {
+		case !u.Name.IsBranch():
+		case u.Old.IsZero():
+			newBranches = true
+		default:
+			hashes = append(hashes, u.Old)
+		}
+	}
+
+	if newBranches {
+		for name, h := range remoteRefs {
+			if name.IsBranch() {
+				hashes = append(hashes, h)
+			}
+		}
+	}
+
+	return hashes
+}
+
+// planPush returns the updates of the remote references done by a push with the given options.
+// By default, the local branches which exist on the remote are pushed.
+func planPush(repository *git.Repository, remoteRefs map[plumbing.ReferenceName]plumbing.Hash,
+	opts PushOptions) ([]RefUpdate, error) {
+	var updates []RefUpdate
+
+	refs, err := repository.References()
+	if err != nil {
+		return nil, err
+	}
+
+	err = refs.ForEach(func(r *plumbing.Reference) error {
+		if r.Type() != plumbing.HashReference {
+			return nil
+		}
+
+		remote, exists := remoteRefs[r.Name()]
+
+		switch {
+		case r.Name().IsBranch() && exists:
+			update, err := planBranchUpdate(repository, r, remote, opts)
+			if update != nil {
+				updates = append(updates, *update)
+			}
+
+			return err
+		case r.Name().IsBranch() && opts.NewBranches,
+			r.Name().IsTag() && opts.Tags && !exists:
+			updates = append(updates, RefUpdate{Name: r.Name(), New: r.Hash()})
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(updates, func(i, j int) bool {
+		return updates[i].Name < updates[j].Name
+	})
+
+	exclude, err := ancestors(repository, pushedRemoteHashes(remoteRefs, updates))
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range updates {
+		if !updates[i].Name.IsBranch() {
+			continue
+		}
+
+		updates[i].Commits, err = countCommits(repository, updates[i].New, exclude)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return updates, nil
+}
+
+// setUpstreams sets the upstream of the pushed new branches which don't have one.
+func setUpstreams(repository *git.Repository, updates []RefUpdate) error {
+	repoConf, err := repository.Config()
+	if err != nil {
+		return err
+	}
+
+	for _, u := range updates {
+		name := u.Name.Short()
+		if !u.Name.IsBranch() || !u.Old.IsZero() || repoConf.Branches[name] != nil {
+			continue
+		}
+
+		repoConf.Branches[name] = &gitconfig.Branch{
+			Name:   name,
+			Remote: git.DefaultRemoteName,
+			Merge:  u.Name,
+		}
+	}
+
+	return repository.Storer.SetConfig(repoConf)
+}
+
+func joinUpdates(updates []RefUpdate) string {
+	names := make([]string, 0, len(updates))
+	for _, u := range updates {
+		names = append(names, u.String())
+	}
+
+	return strings.Join(names, ", ")
+}
+
+func runPush(ctx context.Context, conf *Configuration, repo Repo, status *StatusList, opts PushOptions) {
+	if conf.Mirror {
+		status.appendError(repo.Dir, errMirrorWorkspace)
+
+		return
+	}
+
 	repository, err := git.PlainOpen(repo.Dir)
 	if errors.Is(err, git.ErrRepositoryNotExists) {
 		status.append(repo.Dir, color.RedString("absent"))
@@ -35,7 +367,27 @@ func runPush(conf *Configuration, repo Repo, status *StatusList) {
 		return
 	}
 
-	err = repository.Push(&git.PushOptions{})
+	ahead, err := mayPush(repository, opts)
+	if err != nil {
+		status.appendError(repo.Dir, err)
+
+		return
+	}
+
+	if !ahead {
+		status.append(repo.Dir, color.GreenString("ok"))
+
+		return
+	}
+
+	backend, err := conf.backend(repo)
+	if err != nil {
+		status.appendError(repo.Dir, err)
+
+		return
+	}
+
+	updates, err := backend.Push(ctx, repo, opts)
 
 	if errors.Is(err, git.ErrNonFastForwardUpdate) {
 		status.append(repo.Dir, color.RedString("non-fast-forward update"))
@@ -52,7 +404,9 @@ func runPush(conf *Configuration, repo Repo, status *StatusList) {
 
 	if errors.Is(err, git.NoErrAlreadyUpToDate) {
 		// Ignore NoErrAlreadyUpToDate
-		err = nil
+		status.append(repo.Dir, color.GreenString("ok"))
+
+		return
 	}
 
 	if err != nil {
@@ -61,5 +415,11 @@ func runPush(conf *Configuration, repo Repo, status *StatusList) {
 		return
 	}
 
-	status.append(repo.Dir, color.GreenString("ok"))
+	if opts.DryRun {
+		status.append(repo.Dir, color.YellowString("would push")+"\t"+joinUpdates(updates))
+
+		return
+	}
+
+	status.append(repo.Dir, color.GreenString("ok")+"\t"+joinUpdates(updates))
 }
@@ -0,0 +1,28 @@
+package cmd
+
+import (
+	"path/filepath"
+	"testing"
+
+	git "github.com/go-git/go-git/v5"
+)
+
+func TestMayPushNewBranch(t *testing.T) {
+	setupGit(t)
+
+	dir := filepath.Join(t.TempDir(), "clone")
+	runGit(t, filepath.Dir(dir), "clone", "-q", newBareRepo(t), dir)
+	runGit(t, dir, "branch", "feature")
+
+	repository, err := git.PlainOpen(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, newBranches := range []bool{false, true} {
+		ahead, err := mayPush(repository, PushOptions{NewBranches: newBranches})
+		if err != nil || ahead != newBranches {
+			t.Errorf("mayPush with a new branch and NewBranches %v = %v, %v", newBranches, ahead, err)
+		}
+	}
+}
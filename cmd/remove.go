@@ -0,0 +1,87 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	cobra "github.com/spf13/cobra"
+)
+
+var (
+	errRepoNotFound = errors.New("repository not found in the configuration")
+	errNotPinned    = errors.New("repository is discovered by update, exclude it using init --exclude instead")
+	errUnpushedWork = errors.New("checkout contains unpushed work")
+)
+
+func init() {
+	var deleteCheckout bool
+
+	removeCmd := &cobra.Command{
+		Use:         "remove DIR|URL|OWNER/NAME",
+		Short:       "Remove a repository added using add",
+		Annotations: mutating,
+		Args:        cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			runRemove(loadConfig(), args[0], deleteCheckout)
+		},
+	}
+
+	removeCmd.Flags().BoolVar(&deleteCheckout, "delete", false, "Also delete the checkout, unless it contains unpushed work")
+
+	rootCmd.AddCommand(removeCmd)
+}
+
+// findRepoArg returns the index of the repository given by its directory, URL or full name, or -1.
+func findRepoArg(repos []Repo, arg string) int {
+	for i, r := range repos {
+		if samePath(r.Dir, arg) || sameRepoURL(r.URL, arg) {
+			return i
+		}
+
+		owner, name, err := repoFullName(normalizeURL(r.URL))
+		if err == nil && strings.EqualFold(owner+"/"+name, arg) {
+			return i
+		}
+	}
+
+	return -1
+}
+
+func runRemove(conf *Configuration, arg string, deleteCheckout bool) {
+	i := findRepoArg(conf.Repos, arg)
+	if i < 0 {
+		fatalError(fmt.Errorf("%s: %w", arg, errRepoNotFound))
+
+		return
+	}
+
+	repo := conf.Repos[i]
+
+	if !repo.Pinned {
+		fatalError(fmt.Errorf("%s: %w", repo.Dir, errNotPinned))
+
+		return
+	}
+
+	if deleteCheckout && pathExists(repo.Dir) {
+		// Mirrors only contain data which is on the server
+		if !conf.Mirror {
+			reason, err := unpushedWork(repo.Dir)
+			fatalIfError(err)
+
+			if reason != "" {
+				fatalError(fmt.Errorf("%s: %w: %s", repo.Dir, errUnpushedWork, reason))
+
+				return
+			}
+		}
+
+		fatalIfError(os.RemoveAll(repo.Dir))
+		fmt.Println("Deleted " + repo.Dir + ".")
+	}
+
+	conf.Repos = append(conf.Repos[:i], conf.Repos[i+1:]...)
+	conf.save()
+}
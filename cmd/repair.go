@@ -0,0 +1,195 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"time"
+
+	color "github.com/fatih/color"
+	git "github.com/go-git/go-git/v5"
+	plumbing "github.com/go-git/go-git/v5/plumbing"
+	object "github.com/go-git/go-git/v5/plumbing/object"
+	transport "github.com/go-git/go-git/v5/plumbing/transport"
+	cobra "github.com/spf13/cobra"
+)
+
+const (
+	damageMissingGit   = "missing .git"
+	damageInterrupted  = "interrupted clone"
+	damageCorrupt      = "corrupt objects"
+	backupSuffixFormat = "20060102150405"
+)
+
+func init() {
+	var dryRun bool
+
+	repairCmd := &cobra.Command{
+		Use:         "repair",
+		Short:       "Re-clone broken repositories",
+		Annotations: mutating,
+		Run: func(cmd *cobra.Command, args []string) {
+			repoLoop(func(ctx context.Context, conf *Configuration, repo Repo, status *StatusList) {
+				runRepair(ctx, conf, repo, status, dryRun)
+			}, "Repairing")
+		},
+	}
+
+	repairCmd.Flags().BoolVarP(&dryRun, "dry-run", "n", false, "Only show what would be done")
+	addGroupFlag(repairCmd)
+
+	rootCmd.AddCommand(repairCmd)
+}
+
+// checkObjects reads the commit HEAD points to along with its tree. The files of
+// the tree are also read, unless the objects are fetched on demand by a partial clone.
+func checkObjects(repository *git.Repository, head plumbing.Hash, opts CloneOptions) error {
+	commit, err := repository.CommitObject(head)
+	if err != nil {
+		return err
+	}
+
+	tree, err := commit.Tree()
+	if err != nil {
+		return err
+	}
+
+	if opts.Filter != "" {
+		return nil
+	}
+
+	return tree.Files().ForEach(func(*object.File) error {
+		return nil
+	})
+}
+
+// repoDamage returns the kind of damage of an existing repository,
+// or an empty string if the repository is intact.
+func repoDamage(ctx context.Context, conf *Configuration, repo Repo) (string, error) {
+	repository, err := git.PlainOpen(repo.Dir)
+	if errors.Is(err, git.ErrRepositoryNotExists) {
+		return damageMissingGit, nil
+	}
+
+	if err != nil {
+		return "", err
+	}
+
+	head, err := repository.Head()
+	if errors.Is(err, plumbing.ErrReferenceNotFound) {
+		remote, err := repository.Remote(git.DefaultRemoteName)
+		if err != nil {
+			return "", err
+		}
+
+		// Clones of empty repositories have no commits either
+		_, err = remote.ListContext(ctx, &git.ListOptions{})
+		if errors.Is(err, transport.ErrEmptyRemoteRepository) {
+			return "", nil
+		}
+
+		if err != nil {
+			return "", err
+		}
+
+		return damageInterrupted, nil
+	}
+
+	if err != nil {
+		return damageCorrupt, nil
+	}
+
+	if checkObjects(repository, head.Hash(), conf.cloneOptions(repo)) != nil {
+		return damageCorrupt, nil
+	}
+
+	return "", nil
+}
+
+// hasUserFiles reports whether a repository directory contains files besides the git data.
+func hasUserFiles(conf *Configuration, dir string) (bool, error) {
+	// Mirrors only contain git data, which is downloaded again
+	if conf.Mirror {
+		return false, nil
+	}
+
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return false, err
+	}
+
+	for _, f := range files {
+		if f.Name() != git.GitDirName {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func runRepair(ctx context.Context, conf *Configuration, repo Repo, status *StatusList, dryRun bool) {
+	if !pathExists(repo.Dir) {
+		status.append(repo.Dir, color.RedString("absent"))
+
+		return
+	}
+
+	damage, err := repoDamage(ctx, conf, repo)
+	if err != nil {
+		status.appendError(repo.Dir, err)
+
+		return
+	}
+
+	if damage == "" {
+		status.append(repo.Dir, color.GreenString("ok"))
+
+		return
+	}
+
+	userFiles, err := hasUserFiles(conf, repo.Dir)
+	if err != nil {
+		status.appendError(repo.Dir, err)
+
+		return
+	}
+
+	backup := repo.Dir + ".broken-" + time.Now().Format(backupSuffixFormat)
+	ret := color.RedString(damage)
+
+	if dryRun {
+		if userFiles {
+			status.append(repo.Dir, ret+"\t"+color.YellowString("would move to "+backup+" and re-clone"))
+		} else {
+			status.append(repo.Dir, ret+"\t"+color.YellowString("would re-clone"))
+		}
+
+		return
+	}
+
+	if userFiles {
+		err = os.Rename(repo.Dir, backup)
+		ret += "\t" + color.YellowString("moved to "+backup)
+	} else {
+		err = os.RemoveAll(repo.Dir)
+	}
+
+	if err != nil {
+		status.appendError(repo.Dir, err)
+
+		return
+	}
+
+	var pullStatus StatusList
+
+	runPull(ctx, conf, repo, &pullStatus)
+
+	for _, s := range pullStatus {
+		*status = append(*status, Status{
+			Repo:   s.Repo,
+			State:  ret + "\t" + s.State,
+			Failed: s.Failed,
+		})
+	}
+}
@@ -0,0 +1,190 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"syscall"
+	"time"
+
+	plumbing "github.com/go-git/go-git/v5/plumbing"
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+)
+
+const (
+	failedReposFile = ".gr.failed"
+	defaultRetries  = 2
+	retryBaseDelay  = time.Second
+	retryMaxDelay   = 30 * time.Second
+)
+
+// transientGitOutput holds the output of the git executable caused by network problems.
+var transientGitOutput = []string{
+	"The requested URL returned error: 5",
+	"Connection reset",
+	"Connection timed out",
+	"Operation timed out",
+	"Connection refused",
+	"Failed to connect to",
+	"early EOF",
+	"unexpected disconnect",
+	"the remote end hung up unexpectedly",
+	"RPC failed",
+	"Could not resolve host",
+	"Temporary failure in name resolution",
+}
+
+// isTransientError reports whether an operation which failed with err may succeed when retried.
+func isTransientError(err error) bool {
+	// Operations which timed out or were cancelled as a whole are not retried
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+		return false
+	}
+
+	if errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.ECONNABORTED) ||
+		errors.Is(err, syscall.ETIMEDOUT) ||
+		errors.Is(err, syscall.EPIPE) {
+		return true
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) && dnsErr.Temporary() {
+		return true
+	}
+
+	// go-git wraps the HTTP errors without implementing Unwrap
+	var unexpected *plumbing.UnexpectedError
+	if errors.As(err, &unexpected) {
+		var httpErr *githttp.Err
+		if errors.As(unexpected.Err, &httpErr) {
+			return httpErr.Response.StatusCode >= http.StatusInternalServerError
+		}
+
+		return isTransientError(unexpected.Err)
+	}
+
+	var gerr *gitError
+	if errors.As(err, &gerr) {
+		for _, s := range transientGitOutput {
+			if strings.Contains(gerr.output, s) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// retry calls fn until it succeeds, fails with an error which is not transient or
+// the retries are used up, waiting with exponential backoff between the attempts.
+func retry(ctx context.Context, retries uint, fn func() error) error {
+	delay := retryBaseDelay
+
+	for attempt := uint(0); ; attempt++ {
+		err := fn()
+		if err == nil || attempt >= retries || !isTransientError(err) {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+
+		delay *= 2
+		if delay > retryMaxDelay {
+			delay = retryMaxDelay
+		}
+	}
+}
+
+// readFailedRepos returns the directories of the repositories recorded as failed.
+func readFailedRepos() []string {
+	var failed []string
+
+	bytes, err := ioutil.ReadFile(failedReposFile)
+	if os.IsNotExist(err) {
+		return nil
+	}
+
+	fatalIfError(err)
+
+	err = json.Unmarshal(bytes, &failed)
+	fatalIfError(err)
+
+	return failed
+}
+
+// saveFailedRepos records the repositories which failed, so that they can be retried
+// using pull --retry-failed. The repositories of the status replace their previous
+// record, the others, e.g. when only some repositories were pulled, are kept.
+// The record is removed if no repository failed.
+func saveFailedRepos(status StatusList) {
+	var failed []string
+
+	processed := make(map[string]bool, len(status))
+	for _, s := range status {
+		processed[s.Repo] = true
+	}
+
+	for _, dir := range readFailedRepos() {
+		if !processed[dir] {
+			failed = append(failed, dir)
+		}
+	}
+
+	for _, s := range status {
+		if s.Failed {
+			failed = append(failed, s.Repo)
+		}
+	}
+
+	if len(failed) == 0 {
+		err := os.Remove(failedReposFile)
+		if err != nil && !os.IsNotExist(err) {
+			fatalIfError(err)
+		}
+
+		return
+	}
+
+	bytes, err := json.MarshalIndent(failed, "", "\t")
+	fatalIfError(err)
+	err = writeFileAtomic(failedReposFile, bytes, 0o600)
+	fatalIfError(err)
+}
+
+// failedRepos returns the repositories which failed in the previous runs.
+func failedRepos(conf *Configuration) []Repo {
+	var repos []Repo
+
+	failed := readFailedRepos()
+
+	dirs := make(map[string]bool, len(failed))
+	for _, dir := range failed {
+		dirs[dir] = true
+	}
+
+	for _, repo := range conf.Repos {
+		if dirs[repo.Dir] {
+			repos = append(repos, repo)
+		}
+	}
+
+	return repos
+}
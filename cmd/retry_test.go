@@ -0,0 +1,70 @@
+package cmd
+
+import (
+	"os"
+	"reflect"
+	"testing"
+)
+
+// chdirTemp changes to a temporary directory for the duration of a test.
+func chdirTemp(t *testing.T) {
+	t.Helper()
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = os.Chdir(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { _ = os.Chdir(wd) })
+}
+
+func TestSaveFailedRepos(t *testing.T) {
+	chdirTemp(t)
+
+	conf := &Configuration{Repos: []Repo{{Dir: "./a"}, {Dir: "./b"}, {Dir: "./c"}, {Dir: "./d"}}}
+
+	failedDirs := func() []string {
+		var dirs []string
+		for _, r := range failedRepos(conf) {
+			dirs = append(dirs, r.Dir)
+		}
+
+		return dirs
+	}
+
+	saveFailedRepos(StatusList{
+		{Repo: "./a", Failed: true},
+		{Repo: "./b", Failed: true},
+		{Repo: "./c"},
+		{Repo: "./d"},
+	})
+
+	if got, want := failedDirs(), []string{"./a", "./b"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("failed after a full run = %v, want %v", got, want)
+	}
+
+	// A run of a subset only changes the record of its repositories
+	saveFailedRepos(StatusList{
+		{Repo: "./a"},
+		{Repo: "./c", Failed: true},
+	})
+
+	if got, want := failedDirs(), []string{"./b", "./c"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("failed after a run of a subset = %v, want %v", got, want)
+	}
+
+	saveFailedRepos(StatusList{{Repo: "./b"}, {Repo: "./c"}})
+
+	if got := failedDirs(); got != nil {
+		t.Errorf("failed after retrying all failures = %v, want none", got)
+	}
+
+	if pathExists(failedReposFile) {
+		t.Errorf("%s wasn't removed", failedReposFile)
+	}
+}
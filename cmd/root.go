@@ -1,11 +1,14 @@
 package cmd
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"math"
 	"os"
+	"os/signal"
 	"runtime"
+	"time"
 
 	cobra "github.com/spf13/cobra"
 	term "golang.org/x/term"
@@ -23,26 +26,94 @@ var (
 
 var cFlags *Configuration
 
-type repoOperation func(*Configuration, Repo, *StatusList)
+type repoOperation func(context.Context, *Configuration, Repo, *StatusList)
 
 var (
 	doExit     = os.Exit
 	fatalError = fatalIfError
 )
 
-func repoWorkUnit(fn repoOperation, conf *Configuration, repo Repo, status *StatusList) pool.WorkFunc {
+// opTimeout holds the maximum duration of a single repository operation.
+var opTimeout time.Duration
+
+// interruptContexts returns a context which is cancelled on the first interrupt,
+// after which no new repository operations are started, and a context which is
+// cancelled on the second interrupt, which aborts the running operations.
+// The returned function stops handling the interrupts.
+func interruptContexts() (stopCtx, abortCtx context.Context, release func()) {
+	stopCtx, stop := context.WithCancel(context.Background())
+	abortCtx, abort := context.WithCancel(context.Background())
+	signals := make(chan os.Signal, 1)
+	done := make(chan struct{})
+
+	signal.Notify(signals, os.Interrupt)
+
+	go func() {
+		select {
+		case <-signals:
+			stop()
+			fmt.Println("\nInterrupted, waiting for running operations to finish. " +
+				"Press Ctrl-C again to abort them.")
+		case <-done:
+			return
+		}
+
+		select {
+		case <-signals:
+			abort()
+		case <-done:
+		}
+	}()
+
+	return stopCtx, abortCtx, func() {
+		signal.Stop(signals)
+		close(done)
+		stop()
+		abort()
+	}
+}
+
+func repoWorkUnit(fn repoOperation, stopCtx, abortCtx context.Context, conf *Configuration,
+	repo Repo, status *StatusList) pool.WorkFunc {
 	return func(wu pool.WorkUnit) (interface{}, error) {
-		fn(conf, repo, status)
+		if stopCtx.Err() != nil {
+			status.appendError(repo.Dir, stopCtx.Err())
+
+			return nil, nil
+		}
+
+		ctx := abortCtx
+
+		if opTimeout > 0 {
+			var cancel context.CancelFunc
+
+			ctx, cancel = context.WithTimeout(abortCtx, opTimeout)
+			defer cancel()
+		}
+
+		fn(ctx, conf, repo, status)
 
 		return nil, nil
 	}
 }
 
-func repoLoop(fn repoOperation, msg string) {
+func repoLoop(fn repoOperation, msg string) StatusList {
 	conf := loadConfig()
+
+	return loopRepos(conf, conf.Repos, fn, msg)
+}
+
+// loopRepos runs fn for the given repositories of the workspace and prints their status.
+func loopRepos(conf *Configuration, repos []Repo, fn repoOperation, msg string) StatusList {
 	var status StatusList
 	var p pool.Pool
 
+	repos, err := selectRepos(conf, repos, selectors)
+	fatalIfError(err)
+
+	stopCtx, abortCtx, release := interruptContexts()
+	defer release()
+
 	if conf.Concurrency > 0 && !rootCmd.Flags().Changed("concurrency") {
 		p = pool.NewLimited(conf.Concurrency)
 	} else {
@@ -58,24 +129,28 @@ func repoLoop(fn repoOperation, msg string) {
 	batch := p.Batch()
 
 	go func() {
-		for _, repo := range conf.Repos {
-			batch.Queue(repoWorkUnit(fn, conf, repo, &status))
+		for _, repo := range repos {
+			batch.Queue(repoWorkUnit(fn, stopCtx, abortCtx, conf, repo, &status))
 		}
 
 		batch.QueueComplete()
 	}()
 
 	if term.IsTerminal(int(os.Stdout.Fd())) || flag.Lookup("test.v") != nil {
-		fmt.Printf("\r%s (0/%d)...", msg, len(conf.Repos))
+		fmt.Printf("\r%s (0/%d)...", msg, len(repos))
 
 		i := 1
 		for range batch.Results() {
-			fmt.Printf("\r%s (%d/%d)...", msg, i, len(conf.Repos))
+			fmt.Printf("\r%s (%d/%d)...", msg, i, len(repos))
 			i++
 		}
+	} else {
+		batch.WaitAll()
 	}
 
 	status.print()
+
+	return status
 }
 
 func fatalIfError(err error) {
@@ -99,6 +174,9 @@ func pathExists(path string) bool {
 var rootCmd = &cobra.Command{
 	Use:   "gr",
 	Short: "gr is a github repository management tool",
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		lockRequired = cmd.Annotations[lockAnnotation] != ""
+	},
 	Run: func(cmd *cobra.Command, args []string) {
 		err := cmd.Help()
 		fatalIfError(err)
@@ -114,6 +192,19 @@ func init() {
 		"c",
 		0,
 		"Concurrency for repository jobs")
+
+	rootCmd.PersistentFlags().DurationVarP(
+		&opTimeout,
+		"timeout",
+		"T",
+		0,
+		"Timeout for each repository job (e.g. 10m)")
+
+	rootCmd.PersistentFlags().BoolVar(
+		&waitForLock,
+		"wait",
+		false,
+		"Wait for other runs changing the workspace to finish instead of failing")
 }
 
 // Execute executes the root command.
@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"os"
@@ -18,8 +19,9 @@ const space = byte(' ')
 
 // Status holds a repository's status.
 type Status struct {
-	Repo  string
-	State string
+	Repo   string
+	State  string
+	Failed bool
 }
 
 // StatusList is a convenience wrapper around []Status.
@@ -30,9 +32,28 @@ func (status *Status) toString() string {
 }
 
 func (statuslist *StatusList) appendError(repo string, err error) {
+	state := color.RedString(err.Error())
+
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		state = color.RedString("timed out")
+	case errors.Is(err, context.Canceled):
+		state = color.YellowString("cancelled")
+	}
+
 	*statuslist = append(*statuslist, Status{
-		Repo:  repo,
-		State: color.RedString(err.Error()),
+		Repo:   repo,
+		State:  state,
+		Failed: true,
+	})
+}
+
+// appendFailure adds a failure which isn't an error, so that the repository is retried using --retry-failed.
+func (statuslist *StatusList) appendFailure(repo, state string) {
+	*statuslist = append(*statuslist, Status{
+		Repo:   repo,
+		State:  color.RedString(state),
+		Failed: true,
 	})
 }
 
@@ -78,13 +99,15 @@ func init() {
 		},
 	}
 
+	addGroupFlag(statusCmd)
+
 	rootCmd.AddCommand(statusCmd)
 }
 
 func isRepoDir(path string, repos []Repo) bool {
-	path = path + "/"
+	path = filepath.Clean(path) + "/"
 	for _, r := range repos {
-		repoDir := r.Dir + "/"
+		repoDir := filepath.Clean(r.Dir) + "/"
 		if strings.HasPrefix(repoDir, path) {
 			return true
 		}
@@ -93,31 +116,75 @@ func isRepoDir(path string, repos []Repo) bool {
 	return false
 }
 
-func runLocalStatus() {
-	conf := loadConfig()
-	var status StatusList
+// isWorkspaceFile reports whether a file is one of the files gr keeps in the workspace.
+func isWorkspaceFile(path string) bool {
+	name := filepath.Base(path)
+
+	switch name {
+	case configFile, failedReposFile, lockFile, hooksCacheDir, hooksCacheDir + ".tmp", defaultArchiveDir:
+		return true
+	}
+
+	// Backups and temporary files of the configuration
+	return strings.HasPrefix(name, configFile+".") || strings.HasPrefix(name, "."+configFile+".")
+}
+
+// untrackedPaths returns the files and directories in the base directory which don't belong
+// to any repository. The contents of untracked directories aren't listed separately.
+func untrackedPaths(conf *Configuration) ([]string, error) {
+	var untracked []string
 
 	files, err := filepath.Glob(conf.BaseDir + "/*")
-	fatalIfError(err)
+	if err != nil {
+		return nil, err
+	}
 
 	if conf.SubDirs {
 		parents, err := filepath.Glob(conf.BaseDir + "/*/*")
-		fatalIfError(err)
+		if err != nil {
+			return nil, err
+		}
+
 		files = append(files, parents...)
 	}
 
+	seen := make(map[string]bool)
+
 	for _, f := range files {
-		if !isRepoDir(f, conf.Repos) {
-			status.append(f, color.RedString("untracked"))
+		if isRepoDir(f, conf.Repos) || isWorkspaceFile(f) || seen[filepath.Dir(f)] {
+			continue
 		}
+
+		seen[f] = true
+		untracked = append(untracked, f)
+	}
+
+	return untracked, nil
+}
+
+func runLocalStatus() {
+	conf := loadConfig()
+	var status StatusList
+
+	files, err := untrackedPaths(conf)
+	fatalIfError(err)
+
+	for _, f := range files {
+		status.append(f, color.RedString("untracked"))
 	}
 
 	status.print()
 }
 
-func runStatus(conf *Configuration, repo Repo, status *StatusList) {
+func runStatus(ctx context.Context, conf *Configuration, repo Repo, status *StatusList) {
 	var ret string
 
+	if conf.Mirror {
+		runMirrorStatus(repo, status)
+
+		return
+	}
+
 	if !pathExists(repo.Dir) {
 		status.append(repo.Dir, color.RedString("absent"))
 
@@ -138,63 +205,61 @@ func runStatus(conf *Configuration, repo Repo, status *StatusList) {
 		return
 	}
 
-	head, err := repository.Head()
+	backend, err := conf.backend(repo)
 	if err != nil {
 		status.appendError(repo.Dir, err)
 
 		return
 	}
 
-	if branch := head.Name().Short(); branch == repo.Branch {
-		ret += color.GreenString(branch)
-	} else {
-		ret += color.RedString(branch)
-	}
-
-	workTree, err := repository.Worktree()
+	repoStatus, err := backend.Status(ctx, repo)
 	if err != nil {
 		status.appendError(repo.Dir, err)
 
 		return
 	}
 
-	repoStatus, err := workTree.Status()
-	if err != nil {
-		status.appendError(repo.Dir, err)
-
-		return
+	if repoStatus.Branch == repo.Branch {
+		ret += color.GreenString(repoStatus.Branch)
+	} else {
+		ret += color.RedString(repoStatus.Branch)
 	}
 
-	if repoStatus.IsClean() {
+	if repoStatus.Clean {
 		ret += "\t" + color.GreenString("clean")
 	} else {
 		ret += "\t" + color.RedString("dirty")
 	}
 
-	remote, err := repository.Remote(git.DefaultRemoteName)
+	remoteHash, err := backend.RemoteBranch(ctx, repo, repo.Branch)
 	if err != nil {
 		status.appendError(repo.Dir, err)
 
 		return
 	}
 
-	remoteRef, err := remote.List(&git.ListOptions{})
+	if remoteHash == repoStatus.Head {
+		ret += "\t" + color.GreenString("latest")
+	} else if !remoteHash.IsZero() {
+		ret += "\t" + color.RedString("stale")
+	}
+
+	repoConf, err := repository.Config()
 	if err != nil {
 		status.appendError(repo.Dir, err)
 
 		return
 	}
 
-	for _, r := range remoteRef {
-		if r.Name().String() == "refs/heads/"+repo.Branch {
-			if r.Hash() == head.Hash() {
-				ret += "\t" + color.GreenString("latest")
-			} else {
-				ret += "\t" + color.RedString("stale")
-			}
+	hooks, err := hooksState(conf, repo, repoConf.Raw)
+	if err != nil {
+		status.appendError(repo.Dir, err)
 
-			break
-		}
+		return
+	}
+
+	if hooks != "" {
+		ret += "\t" + hooks
 	}
 
 	status.append(repo.Dir, ret)
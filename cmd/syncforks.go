@@ -0,0 +1,289 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	color "github.com/fatih/color"
+	git "github.com/go-git/go-git/v5"
+	gitconfig "github.com/go-git/go-git/v5/config"
+	plumbing "github.com/go-git/go-git/v5/plumbing"
+	cobra "github.com/spf13/cobra"
+)
+
+var (
+	errNoRemoteHead = errors.New("remote HEAD not found")
+	errInvalidURL   = errors.New("repository URL doesn't contain owner and name")
+)
+
+const (
+	syncUpToDate    = "up to date"
+	syncFastForward = "fast-forward"
+	syncDiverged    = "diverged"
+)
+
+type syncOptions struct {
+	push bool
+	api  bool
+}
+
+type mergeUpstreamResult struct {
+	MergeType string `json:"merge_type"`
+	Message   string `json:"message"`
+}
+
+func init() {
+	var opts syncOptions
+
+	syncForksCmd := &cobra.Command{
+		Use:         "sync-forks",
+		Short:       "Sync the default branch of all forks with their upstream repository",
+		Annotations: mutating,
+		Run: func(cmd *cobra.Command, args []string) {
+			repoLoop(func(ctx context.Context, conf *Configuration, repo Repo, status *StatusList) {
+				runSyncFork(ctx, conf, repo, status, opts)
+			}, "Syncing")
+		},
+	}
+
+	syncForksCmd.Flags().BoolVarP(&opts.push, "push", "p", false, "Push the synced default branch to origin")
+	syncForksCmd.Flags().BoolVarP(&opts.api, "api", "a", false, "Sync forks on the server using the GitHub merge-upstream API")
+	addGroupFlag(syncForksCmd)
+
+	rootCmd.AddCommand(syncForksCmd)
+}
+
+// repoFullName returns the owner and name of a repository from its clone URL.
+func repoFullName(repoURL string) (owner, name string, err error) {
+	u, err := url.Parse(repoURL)
+	if err != nil {
+		return "", "", err
+	}
+
+	parts := strings.Split(strings.Trim(strings.TrimSuffix(u.Path, ".git"), "/"), "/")
+	if len(parts) < 2 {
+		return "", "", fmt.Errorf("%s: %w", u.Redacted(), errInvalidURL)
+	}
+
+	return parts[len(parts)-2], parts[len(parts)-1], nil
+}
+
+// remoteHead returns the branch the HEAD of a remote points to.
+func remoteHead(ctx context.Context, remote *git.Remote) (plumbing.ReferenceName, error) {
+	remoteRefs, err := remote.ListContext(ctx, &git.ListOptions{})
+	if err != nil {
+		return "", err
+	}
+
+	for _, v := range remoteRefs {
+		if v.Name() == plumbing.HEAD && v.Target() != "" {
+			return v.Target(), nil
+		}
+	}
+
+	return "", errNoRemoteHead
+}
+
+func mergeUpstream(ctx context.Context, conf *Configuration, repo Repo) (string, error) {
+	owner, name, err := repoFullName(repo.URL)
+	if err != nil {
+		return "", err
+	}
+
+	client := newGithubClient(conf)
+
+	req, err := client.NewRequest("POST", fmt.Sprintf("repos/%s/%s/merge-upstream", owner, name),
+		map[string]string{"branch": repo.Branch})
+	if err != nil {
+		return "", err
+	}
+
+	var result mergeUpstreamResult
+
+	resp, err := client.Do(ctx, req, &result)
+	if resp != nil && resp.StatusCode == http.StatusConflict {
+		return syncDiverged, nil
+	}
+
+	if err != nil {
+		return "", err
+	}
+
+	if result.MergeType == "none" {
+		return syncUpToDate, nil
+	}
+
+	return result.MergeType, nil
+}
+
+// fastForwardBranch fast-forwards a local branch to the given commit.
+// If the branch is checked out, the worktree must be clean and is updated as well.
+func fastForwardBranch(repository *git.Repository, branch plumbing.ReferenceName, hash plumbing.Hash) error {
+	head, err := repository.Head()
+	if err != nil {
+		return err
+	}
+
+	if head.Name() != branch {
+		return repository.Storer.SetReference(plumbing.NewHashReference(branch, hash))
+	}
+
+	workTree, err := repository.Worktree()
+	if err != nil {
+		return err
+	}
+
+	repoStatus, err := worktreeStatus(repository, workTree)
+	if err != nil {
+		return err
+	}
+
+	if !repoStatus.IsClean() {
+		return git.ErrWorktreeNotClean
+	}
+
+	return workTree.Reset(&git.ResetOptions{
+		Commit: hash,
+		Mode:   git.HardReset,
+	})
+}
+
+func syncFork(ctx context.Context, repository *git.Repository, repo Repo) (string, error) {
+	err := addUpstreamRemote(repository, repo)
+	if err != nil {
+		return "", err
+	}
+
+	err = repository.FetchContext(ctx, &git.FetchOptions{
+		RemoteName: upstreamRemoteName,
+	})
+	if err != nil && !errors.Is(err, git.NoErrAlreadyUpToDate) {
+		return "", err
+	}
+
+	remote, err := repository.Remote(upstreamRemoteName)
+	if err != nil {
+		return "", err
+	}
+
+	upstreamBranch, err := remoteHead(ctx, remote)
+	if err != nil {
+		return "", err
+	}
+
+	upstreamRef, err := repository.Reference(
+		plumbing.NewRemoteReferenceName(upstreamRemoteName, upstreamBranch.Short()), true)
+	if err != nil {
+		return "", err
+	}
+
+	localName := plumbing.NewBranchReferenceName(repo.Branch)
+
+	local, err := repository.Reference(localName, true)
+	if err != nil {
+		return "", err
+	}
+
+	ff, diverged, err := compareCommits(repository, local.Hash(), upstreamRef.Hash())
+	if err != nil {
+		return "", err
+	}
+
+	if diverged {
+		return syncDiverged, nil
+	}
+
+	if !ff {
+		return syncUpToDate, nil
+	}
+
+	err = fastForwardBranch(repository, localName, upstreamRef.Hash())
+	if err != nil {
+		return "", err
+	}
+
+	workTree, err := repository.Worktree()
+	if err != nil {
+		return "", err
+	}
+
+	// The LFS pointers have to be replaced again if the worktree was reset
+	err = pullLFS(ctx, repo, workTree.Filesystem)
+	if err != nil {
+		return "", err
+	}
+
+	return syncFastForward, nil
+}
+
+func (statuslist *StatusList) appendSync(repo, state string) {
+	if state == syncDiverged {
+		statuslist.append(repo, color.RedString(state))
+	} else {
+		statuslist.append(repo, color.GreenString(state))
+	}
+}
+
+func runSyncFork(ctx context.Context, conf *Configuration, repo Repo, status *StatusList, opts syncOptions) {
+	if repo.Parent == "" {
+		return
+	}
+
+	if conf.Mirror && !opts.api {
+		status.appendError(repo.Dir, errMirrorWorkspace)
+
+		return
+	}
+
+	if opts.api {
+		ret, err := mergeUpstream(ctx, conf, repo)
+		if err != nil {
+			status.appendError(repo.Dir, err)
+
+			return
+		}
+
+		status.appendSync(repo.Dir, ret)
+
+		return
+	}
+
+	repository, err := git.PlainOpen(repo.Dir)
+	if errors.Is(err, git.ErrRepositoryNotExists) {
+		status.append(repo.Dir, color.RedString("absent"))
+
+		return
+	}
+
+	if err != nil {
+		status.appendError(repo.Dir, err)
+
+		return
+	}
+
+	ret, err := syncFork(ctx, repository, repo)
+	if err != nil {
+		status.appendError(repo.Dir, err)
+
+		return
+	}
+
+	if opts.push && ret != syncDiverged {
+		refSpec := gitconfig.RefSpec(fmt.Sprintf("refs/heads/%[1]s:refs/heads/%[1]s", repo.Branch))
+
+		err = repository.PushContext(ctx, &git.PushOptions{
+			RefSpecs: []gitconfig.RefSpec{refSpec},
+		})
+		if err != nil && !errors.Is(err, git.NoErrAlreadyUpToDate) {
+			status.appendError(repo.Dir, err)
+
+			return
+		}
+	}
+
+	status.appendSync(repo.Dir, ret)
+}
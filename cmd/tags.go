@@ -0,0 +1,292 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	github "github.com/google/go-github/github"
+	cobra "github.com/spf13/cobra"
+)
+
+const selectorPrefix = "@"
+
+var errNoMatch = errors.New("no repositories match")
+
+// selectors holds the repositories commands are restricted to.
+var selectors []string
+
+func init() {
+	tagsCmd := &cobra.Command{
+		Use:   "tags",
+		Short: "Manage the tags of repositories",
+		Run: func(cmd *cobra.Command, args []string) {
+			err := cmd.Help()
+			fatalIfError(err)
+		},
+	}
+
+	tagsAddCmd := &cobra.Command{
+		Use:         "add TAG REPO...",
+		Short:       "Tag repositories given as @TAG, @GROUP, OWNER/NAME or DIR",
+		Annotations: mutating,
+		Args:        cobra.MinimumNArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+			runTags(loadConfig(), args[0], args[1:], true)
+		},
+	}
+
+	tagsRemoveCmd := &cobra.Command{
+		Use:         "remove TAG REPO...",
+		Short:       "Remove a tag from repositories given as @TAG, @GROUP, OWNER/NAME or DIR",
+		Annotations: mutating,
+		Args:        cobra.MinimumNArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+			runTags(loadConfig(), args[0], args[1:], false)
+		},
+	}
+
+	tagsListCmd := &cobra.Command{
+		Use:   "list",
+		Short: "List the tags and groups along with their repositories",
+		Run: func(cmd *cobra.Command, args []string) {
+			runTagsList(loadConfig())
+		},
+	}
+
+	tagsCmd.AddCommand(tagsAddCmd, tagsRemoveCmd, tagsListCmd)
+	rootCmd.AddCommand(tagsCmd)
+}
+
+// addGroupFlag adds the flag restricting the repositories to a command looping over them.
+func addGroupFlag(cmd *cobra.Command) {
+	cmd.Flags().StringSliceVarP(&selectors, "group", "g", nil,
+		"Only process the repositories matching @TAG, @GROUP, OWNER/NAME or DIR (can be repeated)")
+}
+
+// allTags returns the manual and automatic tags of a repository.
+func (repo *Repo) allTags() []string {
+	return append(append([]string{}, repo.Tags...), repo.AutoTags...)
+}
+
+func (repo *Repo) hasTag(tag string) bool {
+	for _, t := range repo.allTags() {
+		if strings.EqualFold(t, tag) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// matchesSelector reports whether a repository is given by its directory, URL or full name.
+func (repo *Repo) matchesSelector(selector string) bool {
+	if samePath(repo.Dir, selector) || sameRepoURL(repo.URL, selector) {
+		return true
+	}
+
+	owner, name, err := repoFullName(normalizeURL(repo.URL))
+
+	return err == nil && strings.EqualFold(owner+"/"+name, selector)
+}
+
+// selectRepoIndexes adds the indexes of the repositories matching a selector to selected.
+// Selectors starting with @ match the repositories with that tag and the members of that group.
+func selectRepoIndexes(conf *Configuration, selector string, selected map[int]bool, visited map[string]bool) bool {
+	found := false
+
+	if !strings.HasPrefix(selector, selectorPrefix) {
+		for i := range conf.Repos {
+			if conf.Repos[i].matchesSelector(selector) {
+				selected[i] = true
+				found = true
+			}
+		}
+
+		return found
+	}
+
+	name := strings.TrimPrefix(selector, selectorPrefix)
+
+	for i := range conf.Repos {
+		if conf.Repos[i].hasTag(name) {
+			selected[i] = true
+			found = true
+		}
+	}
+
+	if members, ok := conf.Groups[name]; ok && !visited[name] {
+		visited[name] = true
+
+		for _, m := range members {
+			if selectRepoIndexes(conf, m, selected, visited) {
+				found = true
+			}
+		}
+	}
+
+	return found
+}
+
+// selectRepos returns the repositories of repos matching any of the given selectors,
+// which are all repositories if no selectors are given.
+func selectRepos(conf *Configuration, repos []Repo, sel []string) ([]Repo, error) {
+	var result []Repo
+
+	if len(sel) == 0 {
+		return repos, nil
+	}
+
+	selected := make(map[int]bool)
+
+	for _, s := range sel {
+		if !selectRepoIndexes(conf, s, selected, make(map[string]bool)) {
+			return nil, fmt.Errorf("%w %s", errNoMatch, s)
+		}
+	}
+
+	for _, r := range repos {
+		for i := range conf.Repos {
+			if selected[i] && conf.Repos[i].Dir == r.Dir {
+				result = append(result, r)
+
+				break
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// teamTags returns the slugs of the teams of the authenticated user, by full name of their repositories.
+func teamTags(ctx context.Context, client *github.Client) (map[string][]string, error) {
+	tags := make(map[string][]string)
+	opts := &github.ListOptions{PerPage: 100}
+
+	for {
+		teams, resp, err := client.Teams.ListUserTeams(ctx, opts)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, team := range teams {
+			repoOpts := &github.ListOptions{PerPage: 100}
+
+			for {
+				repos, resp, err := client.Teams.ListTeamRepos(ctx, team.GetID(), repoOpts)
+				if err != nil {
+					return nil, err
+				}
+
+				for _, r := range repos {
+					name := strings.ToLower(r.GetFullName())
+					tags[name] = append(tags[name], team.GetSlug())
+				}
+
+				if resp.NextPage == 0 {
+					break
+				}
+
+				repoOpts.Page = resp.NextPage
+			}
+		}
+
+		if resp.NextPage == 0 {
+			return tags, nil
+		}
+
+		opts.Page = resp.NextPage
+	}
+}
+
+// addTeamTags adds the slugs of the teams having access to each repository to its automatic tags.
+func addTeamTags(ctx context.Context, client *github.Client, repos []Repo) error {
+	tags, err := teamTags(ctx, client)
+	if err != nil {
+		return err
+	}
+
+	for i := range repos {
+		owner, name, err := repoFullName(repos[i].URL)
+		if err != nil {
+			continue
+		}
+
+		for _, t := range tags[strings.ToLower(owner+"/"+name)] {
+			if !repos[i].hasTag(t) {
+				repos[i].AutoTags = append(repos[i].AutoTags, t)
+			}
+		}
+	}
+
+	return nil
+}
+
+func runTags(conf *Configuration, tag string, sel []string, add bool) {
+	tag = strings.TrimPrefix(tag, selectorPrefix)
+
+	repos, err := selectRepos(conf, conf.Repos, sel)
+	fatalIfError(err)
+
+	for _, r := range repos {
+		i := findRepo(conf.Repos, r)
+
+		var tags []string
+
+		for _, t := range conf.Repos[i].Tags {
+			if !strings.EqualFold(t, tag) {
+				tags = append(tags, t)
+			}
+		}
+
+		if add {
+			tags = append(tags, tag)
+		}
+
+		conf.Repos[i].Tags = tags
+	}
+
+	conf.save()
+}
+
+func runTagsList(conf *Configuration) {
+	tags := make(map[string][]string)
+
+	for _, r := range conf.Repos {
+		for _, t := range r.allTags() {
+			tags[t] = append(tags[t], r.Dir)
+		}
+	}
+
+	names := make([]string, 0, len(tags))
+	for t := range tags {
+		names = append(names, t)
+	}
+
+	sort.Strings(names)
+
+	w := tabwriter.NewWriter(os.Stdout, 5, 0, 5, space, 0)
+
+	for _, t := range names {
+		_, err := fmt.Fprintln(w, selectorPrefix+t+"\t"+strings.Join(tags[t], " "))
+		fatalIfError(err)
+	}
+
+	groups := make([]string, 0, len(conf.Groups))
+	for g := range conf.Groups {
+		groups = append(groups, g)
+	}
+
+	sort.Strings(groups)
+
+	for _, g := range groups {
+		_, err := fmt.Fprintln(w, selectorPrefix+g+"\tgroup: "+strings.Join(conf.Groups[g], " "))
+		fatalIfError(err)
+	}
+
+	fatalIfError(w.Flush())
+}
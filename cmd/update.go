@@ -6,8 +6,9 @@ import (
 
 func init() {
 	updateCmd := &cobra.Command{
-		Use:   "update",
-		Short: "Update configuration",
+		Use:         "update",
+		Short:       "Update configuration",
+		Annotations: mutating,
 		Run: func(cmd *cobra.Command, args []string) {
 			conf := loadConfig()
 			runInit(conf, true)